@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fjordengineering/urd/store"
+)
+
+// runCommand handles the non-interactive subcommands (e.g. `urd ensure
+// Email`). These exist so urd can be driven from scripts, window-manager
+// keybindings or macro pads without opening the TUI. Every command operates
+// on the same Store methods as the interactive path, so session bookkeeping
+// is identical regardless of how a stream was started.
+func runCommand(s *store.Store, args []string, out io.Writer) error {
+	switch args[0] {
+	case "ensure":
+		return runEnsure(s, args[1:], out, true)
+	case "ensure-stopped":
+		return runEnsure(s, args[1:], out, false)
+	case "restore-trash":
+		return runRestoreTrash(s, args[1:], out)
+	case "total":
+		return runTotal(s, args[1:], out)
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// runEnsure implements `urd ensure NAME` and `urd ensure-stopped NAME`. The
+// name is joined from all remaining arguments so quoting is optional.
+func runEnsure(s *store.Store, args []string, out io.Writer, active bool) error {
+	name := strings.TrimSpace(strings.Join(args, " "))
+	if name == "" {
+		return fmt.Errorf("missing stream name")
+	}
+	id, err := findStreamID(s, name)
+	if err != nil {
+		return err
+	}
+	if active {
+		s.EnsureActive(id)
+	} else {
+		s.EnsureStopped(id)
+	}
+	if err := s.Save(); err != nil {
+		return err
+	}
+	state := "stopped"
+	if active {
+		state = "active"
+	}
+	fmt.Fprintf(out, "%s is %s\n", name, state)
+	return nil
+}
+
+// errNoStream is wrapped by findStreamID when nothing matches, so --start
+// can tell a stream to create apart from an ambiguous name.
+var errNoStream = errors.New("no stream")
+
+// findStreamID resolves a stream name or ID to its ID. Matching names is
+// case-insensitive because names typed on a command line rarely match the
+// original capitalization exactly. Names are unique, but a query can still
+// match one stream's name and another's ID, or two names in a hand-edited
+// file; that's an error rather than a guess at which one was meant.
+func findStreamID(s *store.Store, name string) (string, error) {
+	var ids []string
+	for _, st := range s.Streams {
+		if st.ID == name || strings.EqualFold(st.Name, name) {
+			ids = append(ids, st.ID)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("%w named %q", errNoStream, name)
+	case 1:
+		return ids[0], nil
+	}
+	return "", fmt.Errorf("%q is ambiguous: it matches %d streams", name, len(ids))
+}
+
+// runStart implements --start NAME: start the stream, creating it at the
+// end of the list if there's none by that name yet, so a window-manager
+// binding can name a new task without opening the TUI first. Starting a
+// running stream is a no-op, like urd ensure.
+func runStart(s *store.Store, name string, out io.Writer) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("missing stream name")
+	}
+	id, err := findStreamID(s, name)
+	if errors.Is(err, errNoStream) {
+		if err := s.AddStream(name, len(s.Streams)); err != nil {
+			return err
+		}
+		s.SortStreams()
+		id, err = findStreamID(s, name)
+		fmt.Fprintf(out, "Created %s\n", name)
+	}
+	if err != nil {
+		return err
+	}
+	s.EnsureActive(id)
+	if err := s.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s is active\n", s.StreamByID(id).Name)
+	return nil
+}
+
+// runStop implements --stop NAME: stop the named stream and leave any
+// others running.
+func runStop(s *store.Store, name string, out io.Writer) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("missing stream name")
+	}
+	id, err := findStreamID(s, name)
+	if err != nil {
+		return err
+	}
+	s.EnsureStopped(id)
+	if err := s.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s is stopped\n", s.StreamByID(id).Name)
+	return nil
+}
+
+// runStopAll implements --stop-all: stop every running stream exactly as s
+// does in the TUI, so c there continues the same set.
+func runStopAll(s *store.Store, out io.Writer) error {
+	n := len(s.ActiveStreams())
+	s.StopAll()
+	if err := s.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Stopped %d streams\n", n)
+	return nil
+}
+
+// runRestoreTrash implements `urd restore-trash [NAME]`. Without a name it
+// lists the trash so the user can see what is recoverable; with a name it
+// restores the matching stream.
+func runRestoreTrash(s *store.Store, args []string, out io.Writer) error {
+	name := strings.TrimSpace(strings.Join(args, " "))
+	if name == "" {
+		if len(s.Trash) == 0 {
+			fmt.Fprintln(out, "Trash is empty")
+			return nil
+		}
+		for _, st := range s.Trash {
+			fmt.Fprintf(out, "%s\t(deleted %s)\n", st.Name, st.DeletedAt.Format("2006-01-02 15:04"))
+		}
+		return nil
+	}
+	for _, st := range s.Trash {
+		if strings.EqualFold(st.Name, name) {
+			if err := s.RestoreStream(st.ID); err != nil {
+				return err
+			}
+			if err := s.Save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Restored %s\n", st.Name)
+			return nil
+		}
+	}
+	return fmt.Errorf("no stream named %q in trash", name)
+}
+
+// runTotal implements `urd total [--since DATE] [--until DATE]`, printing
+// the wall-clock time tracked in the range. Both dates are YYYY-MM-DD in
+// local time and both are inclusive: --since starts at the beginning of its
+// day and --until runs to the end of its day, where days begin at the
+// store's DayStartHour. Sessions crossing a boundary
+// are clipped, so a month's totals add up exactly across adjacent ranges.
+// With --by-stream it prints each non-archived stream's time in the range,
+// clipped the same way, above the wall-clock total.
+func runTotal(s *store.Store, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("total", flag.ContinueOnError)
+	fs.SetOutput(out)
+	sinceStr := fs.String("since", "", "first day to include (YYYY-MM-DD)")
+	untilStr := fs.String("until", "", "last day to include (YYYY-MM-DD)")
+	byStream := fs.Bool("by-stream", false, "list each stream's time above the total")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var since, until time.Time
+	var err error
+	if *sinceStr != "" {
+		if since, err = parseDay(*sinceStr); err != nil {
+			return err
+		}
+		since = s.DayOn(since, 0)
+	}
+	if *untilStr != "" {
+		if until, err = parseDay(*untilStr); err != nil {
+			return err
+		}
+		until = s.DayOn(until, 1)
+	}
+	if !since.IsZero() && !until.IsZero() && !until.After(since) {
+		return fmt.Errorf("--until must not be before --since")
+	}
+	total := formatDuration(s.WallClockBetween(since, until))
+	if !*byStream {
+		fmt.Fprintln(out, total)
+		return nil
+	}
+	width := len("Total")
+	for _, st := range s.Streams {
+		if !st.Archived {
+			width = max(width, len(st.Name))
+		}
+	}
+	for _, st := range s.Streams {
+		if !st.Archived {
+			fmt.Fprintf(out, "%-*s  %s\n", width, st.Name, formatDuration(s.StreamTimeBetween(st.ID, since, until)))
+		}
+	}
+	fmt.Fprintf(out, "%-*s  %s\n", width, "Total", total)
+	return nil
+}
+
+// parseDay parses a YYYY-MM-DD date as local midnight.
+func parseDay(s string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// runImport implements --import FILE: bulk-create streams from a list of
+// names, one per line (see ImportNames). "-" reads stdin so a list can be
+// piped in from another tool.
+func runImport(s *store.Store, path string, out io.Writer) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	n, err := s.ImportNames(r)
+	if err != nil {
+		return fmt.Errorf("importing %s: %w", path, err)
+	}
+	if err := s.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Imported %d streams\n", n)
+	return nil
+}
+
+// runRepair implements --repair: it applies Store.Repair on top of the
+// repairs LoadStore already made, saves if anything changed, and lists
+// every fix so the user knows what happened to their data.
+func runRepair(s *store.Store, out io.Writer) error {
+	fixed := append(slices.Clone(s.Repairs), s.Repair()...)
+	if len(fixed) == 0 {
+		fmt.Fprintln(out, "Nothing to repair")
+		return nil
+	}
+	if err := s.Save(); err != nil {
+		return err
+	}
+	for _, f := range fixed {
+		fmt.Fprintf(out, "Repaired: %s\n", f)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fjordengineering/urd/store"
+)
+
+func TestRunEnsureByName(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+
+	var out bytes.Buffer
+	for i := 0; i < 2; i++ {
+		if err := runCommand(s, []string{"ensure", "email"}, &out); err != nil {
+			t.Fatalf("ensure failed: %v", err)
+		}
+	}
+	if !s.Streams[0].Active {
+		t.Fatal("expected stream to be active")
+	}
+
+	if err := runCommand(s, []string{"ensure-stopped", "Email"}, &out); err != nil {
+		t.Fatalf("ensure-stopped failed: %v", err)
+	}
+	if s.Streams[0].Active {
+		t.Fatal("expected stream to be stopped")
+	}
+}
+
+func TestRunEnsureUnknownStream(t *testing.T) {
+	s := newTestStore(t)
+	var out bytes.Buffer
+	if err := runCommand(s, []string{"ensure", "nope"}, &out); err == nil {
+		t.Fatal("expected error for unknown stream")
+	}
+}
+
+func TestRunRestoreTrash(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	s.DeleteStream(s.Streams[0].ID)
+
+	var out bytes.Buffer
+	if err := runCommand(s, []string{"restore-trash", "email"}, &out); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if len(s.Streams) != 1 || len(s.Trash) != 0 {
+		t.Fatal("expected stream restored from trash")
+	}
+}
+
+func TestRunTotalInclusiveDays(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Date(2024, 1, 31, 23, 0, 0, 0, time.Local)
+	end := start.Add(2 * time.Hour) // runs into Feb 1st
+	s.Sessions = []store.Session{{Start: start, End: &end}}
+
+	var out bytes.Buffer
+	if err := runCommand(s, []string{"total", "--since", "2024-01-01", "--until", "2024-01-31"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "1h 00m 00s" {
+		t.Fatalf("expected only the January hour, got %q", got)
+	}
+
+	if err := runCommand(s, []string{"total", "--since", "2024-02-02", "--until", "2024-02-01"}, &out); err == nil {
+		t.Fatal("expected error for inverted range")
+	}
+}
+
+func TestRunTotalByStreamClipsBothEnds(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	s.AddStream("Old", 1)
+	s.Streams[1].Archived = true
+	email, old := s.Streams[0].ID, s.Streams[1].ID
+	start := time.Date(2024, 1, 31, 23, 0, 0, 0, time.Local)
+	end := time.Date(2024, 2, 2, 1, 0, 0, 0, time.Local) // one hour either side of Feb 1st
+	s.Sessions = []store.Session{{Start: start, End: &end, Spans: []store.Span{
+		{StreamID: email, Start: start, End: &end},
+		{StreamID: old, Start: start, End: &end},
+	}}}
+
+	var out bytes.Buffer
+	if err := runCommand(s, []string{"total", "--by-stream", "--since", "2024-02-01", "--until", "2024-02-01"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "Email  24h 00m 00s\nTotal  24h 00m 00s\n"
+	if got := out.String(); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRunStartCreatesAndStarts(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	var out bytes.Buffer
+	if err := runStart(s, "Review", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Created Review\nReview is active\n" || len(s.Streams) != 2 {
+		t.Fatalf("expected Review created and started, got %q", out.String())
+	}
+	if err := runStart(s, "email", &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.ActiveStreams()) != 2 || len(s.Sessions) != 1 {
+		t.Fatal("expected both running in one session")
+	}
+	if err := runStart(s, "Email", &out); err != nil || len(s.ActiveStreams()) != 2 {
+		t.Fatalf("expected starting a running stream to keep it running, got %v", err)
+	}
+}
+
+func TestRunStartRejectsBlankName(t *testing.T) {
+	s := newTestStore(t)
+	var out bytes.Buffer
+	if err := runStart(s, "   ", &out); err == nil || err.Error() != "missing stream name" {
+		t.Fatalf("expected missing stream name, got %v", err)
+	}
+	if len(s.Streams) != 0 || out.Len() != 0 {
+		t.Fatal("expected no stream created for a blank name")
+	}
+}
+
+func TestRunStop(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	s.AddStream("Review", 1)
+	s.ToggleStream(s.Streams[0].ID)
+	s.ToggleStream(s.Streams[1].ID)
+
+	var out bytes.Buffer
+	if err := runStop(s, "review", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Review is stopped\n" || len(s.ActiveStreams()) != 1 {
+		t.Fatalf("expected only Review stopped, got %q", out.String())
+	}
+	if err := runStop(s, "Nope", &out); err == nil {
+		t.Fatal("expected an error for an unknown stream")
+	}
+	if err := runStop(s, " ", &out); err == nil || len(s.ActiveStreams()) != 1 {
+		t.Fatal("expected a blank name to be an error, not stop everything")
+	}
+	out.Reset()
+	if err := runStopAll(s, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Stopped 1 streams\n" || s.HasActive() || s.Sessions[0].End == nil {
+		t.Fatalf("expected everything stopped and the session closed, got %q", out.String())
+	}
+	if len(s.LastActive) != 1 || s.LastActive[0] != s.Streams[0].ID {
+		t.Fatal("expected c to be able to continue what --stop stopped")
+	}
+}
+
+func TestBareStopStopsEverything(t *testing.T) {
+	for _, tt := range []struct{ args, want string }{
+		{"--stop", "--stop="},
+		{"-stop --file x.json", "-stop= --file x.json"},
+		{"--stop Email", "--stop Email"},
+		{"--stop=Email", "--stop=Email"},
+		{"-- --stop", "-- --stop"},
+	} {
+		if got := strings.Join(bareStop(strings.Fields(tt.args)), " "); got != tt.want {
+			t.Errorf("bareStop(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRunRepair(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("store", "testdata", "urd-inconsistent.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "urd.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.LoadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := runRepair(s, &out); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "Repaired: "); n != 5 {
+		t.Fatalf("expected load's repair and Repair's four listed, got:\n%s", out.String())
+	}
+
+	loaded, err := store.LoadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runRepair(loaded, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Nothing to repair\n" {
+		t.Fatalf("expected the saved file to be consistent, got %q", out.String())
+	}
+}
+
+func TestRunImport(t *testing.T) {
+	s := newTestStore(t)
+	path := filepath.Join(t.TempDir(), "names.txt")
+	if err := os.WriteFile(path, []byte("Email\nReview\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := runImport(s, path, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "Imported 2 streams\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+	loaded, err := store.LoadStore(s.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Streams) != 2 {
+		t.Fatalf("expected the import to be saved, got %d streams", len(loaded.Streams))
+	}
+}
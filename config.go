@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fjordengineering/urd/store"
+)
+
+// Config holds standing preferences from the optional config file, for
+// settings that would otherwise have to be passed as flags on every run or
+// set by hand in the data file. Every field is optional. A flag given on
+// the command line always beats the config file.
+// The flag-level settings (File, FileMode, Idle, NotifyAfter, NoColor)
+// mirror the flags of the same name; Idle and NotifyAfter are duration
+// strings like "45m". The store-level ones are pointers so that "not set"
+// can be told apart from a zero value. When set, they replace the
+// same-named fields of the data file on every start (see apply). Set
+// day_start_hour to 0 here to force midnight even if the data file says
+// otherwise. Backups is how many rotated copies of the data file to keep
+// (default defaultBackups, 0 for none). Keys overrides the TUI's key
+// bindings; see Keymap.
+type Config struct {
+	File            string  `json:"file,omitempty"`
+	FileMode        string  `json:"file_mode,omitempty"`
+	Idle            string  `json:"idle,omitempty"`
+	NotifyAfter     string  `json:"notify_after,omitempty"`
+	NoColor         bool    `json:"no_color,omitempty"`
+	SortMode        *string `json:"sort_mode,omitempty"`
+	DayStartHour    *int    `json:"day_start_hour,omitempty"`
+	RoundingMinutes *int    `json:"rounding_minutes,omitempty"`
+	Backups         *int    `json:"backups,omitempty"`
+	Keys            *Keymap `json:"keys,omitempty"`
+
+	idle        time.Duration
+	notifyAfter time.Duration
+}
+
+// ConfigPath returns where the config file lives:
+// $XDG_CONFIG_HOME/urd/config.json, falling back to ~/.config like
+// DataPath falls back to ~/.local/share. Unlike DataPath it creates
+// nothing, since the file is optional.
+func ConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "urd", "config.json"), nil
+}
+
+// LoadConfig reads the config file at path. A missing file isn't an error:
+// it returns an empty Config, which changes nothing. A file that exists but
+// doesn't parse, has an unknown key or holds an invalid value is an error.
+// Quietly ignoring a typo would leave the user wondering why a setting has
+// no effect.
+// A leading ~/ in File is expanded, since the file is written by hand.
+func LoadConfig(path string) (*Config, error) {
+	c := &Config{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if rest, ok := strings.CutPrefix(c.File, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		c.File = filepath.Join(home, rest)
+	}
+	if c.Idle != "" {
+		if c.idle, err = time.ParseDuration(c.Idle); err != nil {
+			return nil, fmt.Errorf("%s: invalid idle %q", path, c.Idle)
+		}
+	}
+	if c.NotifyAfter != "" {
+		if c.notifyAfter, err = time.ParseDuration(c.NotifyAfter); err != nil {
+			return nil, fmt.Errorf("%s: invalid notify_after %q", path, c.NotifyAfter)
+		}
+	}
+	if c.SortMode != nil && !store.ValidSortMode(*c.SortMode) {
+		return nil, fmt.Errorf("%s: unknown sort_mode %q", path, *c.SortMode)
+	}
+	if c.DayStartHour != nil && (*c.DayStartHour < 0 || *c.DayStartHour > 23) {
+		return nil, fmt.Errorf("%s: day_start_hour must be 0-23, got %d", path, *c.DayStartHour)
+	}
+	if c.RoundingMinutes != nil && *c.RoundingMinutes < 0 {
+		return nil, fmt.Errorf("%s: rounding_minutes must not be negative", path)
+	}
+	if c.Backups != nil && *c.Backups < 0 {
+		return nil, fmt.Errorf("%s: backups must not be negative", path)
+	}
+	if c.Keys != nil {
+		if err := DefaultKeymap().withOverrides(*c.Keys).validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// apply copies the store-level preferences that are set onto s. It runs
+// right after loading, so the TUI, reports and commands all see them. They
+// are saved into the data file with everything else, but the config file
+// wins again on the next start. The backup count is the exception: it's
+// runtime-only, like the file mode.
+func (c *Config) apply(s *store.Store) {
+	if c.SortMode != nil {
+		s.SortMode = *c.SortMode
+		s.SortStreams()
+	}
+	if c.DayStartHour != nil {
+		s.DayStartHour = *c.DayStartHour
+	}
+	if c.RoundingMinutes != nil {
+		s.RoundingMinutes = *c.RoundingMinutes
+	}
+	if c.Backups != nil {
+		s.BackupCount = *c.Backups
+	}
+}
+
+// defaultBackups is how many backups of the data file are kept unless the
+// config file says otherwise.
+const defaultBackups = 3
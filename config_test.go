@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fjordengineering/urd/store"
+)
+
+func TestLoadConfigMissingFileIsEmpty(t *testing.T) {
+	c, err := LoadConfig(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStore(t)
+	s.DayStartHour = 4
+	c.apply(s)
+	if s.DayStartHour != 4 {
+		t.Fatalf("an empty config should change nothing, got day start %d", s.DayStartHour)
+	}
+}
+
+func TestLoadConfigAppliesValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"idle": "45m", "notify_after": "1h", "sort_mode": "alpha", "day_start_hour": 0, "rounding_minutes": 15}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.idle != 45*time.Minute || c.notifyAfter != time.Hour {
+		t.Fatalf("durations not parsed: idle %v, notify after %v", c.idle, c.notifyAfter)
+	}
+
+	s := newTestStore(t)
+	s.DayStartHour = 4
+	s.AddStream("Zeta", 0)
+	s.AddStream("Alpha", 1)
+	c.apply(s)
+	if s.DayStartHour != 0 {
+		t.Fatalf("day_start_hour 0 should override the data file, got %d", s.DayStartHour)
+	}
+	if s.RoundingMinutes != 15 || s.SortMode != store.SortAlpha {
+		t.Fatalf("got rounding %d, sort %q", s.RoundingMinutes, s.SortMode)
+	}
+	if s.Streams[0].Name != "Alpha" {
+		t.Fatalf("streams should be re-sorted by the configured order, got %q first", s.Streams[0].Name)
+	}
+}
+
+func TestLoadConfigRejectsBadValues(t *testing.T) {
+	for _, data := range []string{
+		`{"idle": "soon"}`,
+		`{"sort_mode": "random"}`,
+		`{"day_start_hour": 24}`,
+		`{"rounding_minutes": -5}`,
+		`{"idle": `,
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("expected an error for %s", data)
+		}
+	}
+}
+
+func TestConfigPathUsesXDG(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+	got, err := ConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("/tmp/xdg", "urd", "config.json"); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestLoadConfigKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"keys": {"toggle": ["x", "enter"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := DefaultKeymap().withOverrides(*c.Keys); !k.Toggle.has("x") || k.Toggle.has(" ") {
+		t.Fatalf("got toggle %v", k.Toggle)
+	}
+
+	for _, data := range []string{
+		`{"keys": {"toggel": ["x"]}}`,
+		`{"keys": {"up": ["j"]}}`,
+		`{"sort": "alpha"}`,
+	} {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("expected an error for %s", data)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/fjordengineering/urd/store"
+)
+
+// eventLine is one line written by --events-out: what happened, and the
+// totals right after it, so a dashboard can follow along without reading
+// urd.json. Type is one of the store's event types, or "tick" for the
+// once-a-minute summary while streams are running. Streams maps each
+// stream's name to its elapsed seconds, as shown in the TUI.
+type eventLine struct {
+	At               time.Time        `json:"at"`
+	Type             string           `json:"type"`
+	StreamID         string           `json:"stream_id,omitempty"`
+	Stream           string           `json:"stream,omitempty"`
+	Detail           string           `json:"detail,omitempty"`
+	WallClockSeconds int64            `json:"wall_clock_seconds"`
+	Active           []string         `json:"active"`
+	Streams          map[string]int64 `json:"streams"`
+}
+
+// newEventLine describes e against the current state of s.
+func newEventLine(s *store.Store, e store.Event) eventLine {
+	l := eventLine{
+		At:               e.At,
+		Type:             e.Type,
+		StreamID:         e.StreamID,
+		Detail:           e.Detail,
+		WallClockSeconds: int64(s.TotalWallClock().Seconds()),
+		Active:           []string{},
+		Streams:          make(map[string]int64),
+	}
+	for _, st := range slices.Concat(s.Streams, s.Trash) {
+		if st.ID == e.StreamID {
+			l.Stream = st.Name
+		}
+	}
+	for _, st := range s.Streams {
+		l.Streams[st.Name] = int64(s.Elapsed(st.ID).Seconds())
+	}
+	for _, st := range s.ActiveStreams() {
+		l.Active = append(l.Active, st.Name)
+	}
+	return l
+}
+
+// eventsOutBuffer is how many lines can wait for a slow reader before new
+// ones are dropped.
+const eventsOutBuffer = 256
+
+// eventWriter appends JSON lines to a file or FIFO from its own goroutine,
+// so the TUI never waits on it. Opening a FIFO blocks until something reads
+// it, and writing blocks while the reader is slow; both happen on the
+// goroutine, and send drops lines rather than wait once the buffer is full.
+// When a write fails, e.g. because the FIFO's reader went away, the path is
+// reopened for the next line.
+type eventWriter struct {
+	path  string
+	lines chan []byte
+	done  chan struct{}
+}
+
+func newEventWriter(path string) *eventWriter {
+	w := &eventWriter{
+		path:  path,
+		lines: make(chan []byte, eventsOutBuffer),
+		done:  make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+func (w *eventWriter) loop() {
+	defer close(w.done)
+	var f *os.File
+	for line := range w.lines {
+		if f == nil {
+			var err error
+			f, err = os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				continue
+			}
+		}
+		if _, err := f.Write(line); err != nil {
+			f.Close()
+			f = nil
+		}
+	}
+	if f != nil {
+		f.Close()
+	}
+}
+
+// send queues l without blocking.
+func (w *eventWriter) send(l eventLine) {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return
+	}
+	select {
+	case w.lines <- append(data, '\n'):
+	default:
+	}
+}
+
+// Close flushes what's queued, giving up after timeout so a FIFO nobody
+// is reading can't hold up exit.
+func (w *eventWriter) Close(timeout time.Duration) {
+	close(w.lines)
+	select {
+	case <-w.done:
+	case <-time.After(timeout):
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventsOutWritesOneLinePerChange(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(path, []byte("{\"type\":\"earlier\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := initialModel(s)
+	m.events = newEventWriter(path)
+	m.eventsSeen = len(s.Events)
+	m = pressKeys(m, "enter")
+	updated, _ := m.Update(tickMsg(time.Now()))
+	m = updated.(model)
+	m = pressKeys(m, "enter")
+	m.events.Close(time.Second)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []eventLine
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var l eventLine
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			t.Fatalf("line %q isn't JSON: %v", sc.Text(), err)
+		}
+		lines = append(lines, l)
+	}
+
+	var types []string
+	for _, l := range lines {
+		types = append(types, l.Type)
+	}
+	if len(lines) != 4 || types[0] != "earlier" || types[1] != "start" || types[2] != "tick" || types[3] != "stop" {
+		t.Fatalf("expected the old line kept and start, tick, stop appended, got %v", types)
+	}
+	if lines[1].Stream != "Email" || len(lines[1].Active) != 1 {
+		t.Fatalf("expected the start line to name the stream and show it active, got %+v", lines[1])
+	}
+	if _, ok := lines[3].Streams["Email"]; !ok || len(lines[3].Active) != 0 {
+		t.Fatalf("expected totals with nothing active after the stop, got %+v", lines[3])
+	}
+}
+
+func TestEventWriterDropsRatherThanBlocks(t *testing.T) {
+	// Nothing drains lines, as with a FIFO nobody reads.
+	w := &eventWriter{lines: make(chan []byte, 1), done: make(chan struct{})}
+	finished := make(chan struct{})
+	go func() {
+		for range 3 {
+			w.send(eventLine{Type: "start"})
+		}
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("send blocked on a full buffer")
+	}
+	if len(w.lines) != 1 {
+		t.Fatalf("expected the buffer full and the rest dropped, got %d queued", len(w.lines))
+	}
+}
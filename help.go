@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// helpSection is one group of keys in the help overlay. Each entry is a
+// key (as shown) and what it does.
+type helpSection struct {
+	title string
+	keys  [][2]string
+}
+
+// helpSections lists every key of the stream list, grouped by what it's
+// for. Rebindable actions are named from k, so the overlay always shows the
+// keys actually in use. A new key belongs here as well as in the README.
+func helpSections(k Keymap) []helpSection {
+	return []helpSection{
+		{"Navigation", [][2]string{
+			{k.Down.help() + "/" + k.Up.help(), "move down/up"},
+			{"1-9, 0", "jump to stream by number"},
+			{".", "jump to the running or busiest stream"},
+			{"/", "filter by name (esc clears)"},
+		}},
+		{"Tracking", [][2]string{
+			{k.Toggle.help(), "start/stop the stream"},
+			{"f", "focus: stop the others and start this one"},
+			{"A", "ensure the stream is running"},
+			{"X", "ensure the stream is stopped"},
+			{"t", "start with a backdated time"},
+			{"p", "pause / resume the running streams"},
+			{"P", "start a 25-minute pomodoro / end it early"},
+			{k.StopAll.help(), "stop all"},
+			{"S", "stop all except this one"},
+			{k.Continue.help(), "continue the previous streams"},
+			{"I", "mark as the interruption stream"},
+			{"F", "toggle focus mode"},
+		}},
+		{"Streams", [][2]string{
+			{k.AddBelow.help() + "/" + k.AddAbove.help(), "add below/above"},
+			{"e", "rename"},
+			{"n", "add a note"},
+			{"g", "set a target time"},
+			{"C", "cycle the color"},
+			{"K/J", "pin and move up/down"},
+			{"m/M", "mark a merge target / merge into it"},
+			{"|", "split time off into a new stream"},
+			{"a", "archive or unarchive"},
+			{"x", "exclude from the total"},
+			{"R", "reset the time to zero"},
+			{k.Delete.help() + k.Delete.help(), "delete to the trash (asks unless empty)"},
+			{"D", "delete to the trash without asking"},
+		}},
+		{"Time", [][2]string{
+			{"T", "log past time"},
+			{"L", "log a past session on the stream"},
+			{"+", "add or take off time, e.g. +15m or -1h"},
+			{"N", "count totals from now on"},
+			{"u", "undo"},
+		}},
+		{"Views", [][2]string{
+			{"tab", "today mode"},
+			{"=", "cycle the sort order"},
+			{"%", "percentages of wall clock / of stream time"},
+			{"H", "show/hide archived streams"},
+			{"h", "stream history"},
+			{"v", "sessions"},
+			{"Z", "trash"},
+			{"?", "this help"},
+		}},
+		{"Quit", [][2]string{
+			{k.Quit.help(), "save and quit"},
+			{"Q", "save and quit without the summary"},
+		}},
+	}
+}
+
+// helpLines renders the sections one row per key, with a blank line
+// between sections.
+func (m model) helpLines() []string {
+	keyCol := lipgloss.NewStyle().Width(10)
+	var lines []string
+	for i, sec := range helpSections(m.keys) {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "  "+m.styles.title.UnsetMarginBottom().Render(sec.title))
+		for _, kv := range sec.keys {
+			lines = append(lines, "    "+keyCol.Render(kv[0])+m.styles.faint.Render(kv[1]))
+		}
+	}
+	return lines
+}
+
+// helpHead and helpFooter frame the overlay's rows.
+func (m model) helpHead() string {
+	return m.styles.title.Render("urd - Keys") + "\n\n"
+}
+
+func (m model) helpFooter(text string) string {
+	return m.styles.help.Render("\n  " + text)
+}
+
+// helpSpace is how many help rows fit between the overlay's title and its
+// footer; 0 before the terminal size is known, meaning all of them.
+func (m model) helpSpace() int {
+	if m.height <= 0 {
+		return 0
+	}
+	return max(m.height-lipgloss.Height(m.helpHead()+m.helpFooter("")), 1)
+}
+
+// maxHelpOffset is the furthest the overlay can scroll while still filling
+// the screen.
+func (m model) maxHelpOffset() int {
+	if m.helpSpace() == 0 {
+		return 0
+	}
+	return max(len(m.helpLines())-m.helpSpace(), 0)
+}
+
+// updateHelp handles keys while the help overlay is open: up/down scroll
+// it when it's taller than the terminal, ? and esc close it, and quit
+// works as it does from the other views.
+func (m model) updateHelp(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	switch {
+	case m.keys.Quit.has(key):
+		m.store.Save()
+		return m, tea.Quit
+	case m.keys.Down.has(key):
+		m.helpOffset = min(m.helpOffset+1, m.maxHelpOffset())
+		return m, nil
+	case m.keys.Up.has(key):
+		m.helpOffset = max(m.helpOffset-1, 0)
+		return m, nil
+	}
+	switch key {
+	case "?", "esc":
+		m.showHelp = false
+		m.helpOffset = 0
+	}
+	return m, nil
+}
+
+// viewHelp renders the help overlay in place of the stream list.
+func (m model) viewHelp() string {
+	lines := m.helpLines()
+	if space := m.helpSpace(); space > 0 && len(lines) > space {
+		start := min(m.helpOffset, len(lines)-space)
+		lines = lines[start : start+space]
+	}
+	body := m.helpHead() + strings.Join(lines, "\n") + "\n"
+
+	help := "?/esc back · " + m.keys.Quit.help() + " quit"
+	if m.maxHelpOffset() > 0 {
+		help = fmt.Sprintf("%s/%s scroll (%d/%d) · %s", m.keys.Down.help(), m.keys.Up.help(), m.helpOffset+1, m.maxHelpOffset()+1, help)
+	}
+	return pinFooter(body, m.helpFooter(help), m.height)
+}
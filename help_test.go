@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHelpOverlay(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	m := initialModel(s)
+	m.styles = newStyles(false)
+	m.keys = DefaultKeymap().withOverrides(Keymap{Toggle: keyBinding{"x"}})
+
+	m = pressKeys(m, "?")
+	view := m.View()
+	if !m.showHelp || !strings.Contains(view, "Streams") || !strings.Contains(view, "split time off") {
+		t.Fatalf("expected the grouped key list:\n%s", view)
+	}
+	if !strings.Contains(view, "x         start/stop") {
+		t.Fatal("expected the overlay to show the remapped toggle key")
+	}
+	m = pressKeys(m, "x")
+	if s.HasActive() {
+		t.Fatal("expected list keys ignored while the overlay is open")
+	}
+	m = pressKeys(m, "esc")
+	if m.showHelp {
+		t.Fatal("expected esc to close the overlay")
+	}
+}
+
+func TestHelpOverlayScrolls(t *testing.T) {
+	s := newTestStore(t)
+	m := initialModel(s)
+	m.styles = newStyles(false)
+	m.height = 12
+	m = pressKeys(m, "?")
+	view := m.View()
+	if h := strings.Count(view, "\n") + 1; h != 12 {
+		t.Fatalf("expected the overlay to fit 12 rows, got %d:\n%s", h, view)
+	}
+	if !strings.Contains(view, "Navigation") || strings.Contains(view, "Quit") {
+		t.Fatal("expected the overlay to start at the top")
+	}
+	for range 100 {
+		m = pressKeys(m, "j")
+	}
+	if m.helpOffset != m.maxHelpOffset() || !strings.Contains(m.View(), "without the summary") {
+		t.Fatal("expected j to scroll to the end and stop there")
+	}
+	m = pressKeys(m, "?")
+	if m.showHelp || m.helpOffset != 0 {
+		t.Fatal("expected ? to close the overlay and reset the scroll")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// keyBinding is the set of keys, in Bubble Tea's KeyMsg.String() form
+// ("j", "ctrl+j", "enter", " "), that trigger one action.
+type keyBinding []string
+
+func (b keyBinding) has(key string) bool {
+	return slices.Contains(b, key)
+}
+
+// help names the binding's first key for the help line and prompts.
+func (b keyBinding) help() string {
+	if len(b) == 0 {
+		return "(unbound)"
+	}
+	if b[0] == " " {
+		return "space"
+	}
+	return b[0]
+}
+
+// Keymap holds the rebindable actions of the stream list and its y/n
+// prompts. Everything else keeps a fixed key: those are mostly mnemonic
+// letters, and rebinding them all would leave little free to bind to.
+// Text prompts always use enter to accept and esc to cancel, like the text
+// input they wrap. Delete is pressed twice, vim-style, as with the default
+// "dd".
+// In the config file, "keys" maps action names (the JSON tags) to key
+// lists; an action that's listed replaces its defaults entirely, so
+// {"toggle": ["x"]} frees enter and space.
+type Keymap struct {
+	Up       keyBinding `json:"up,omitempty"`
+	Down     keyBinding `json:"down,omitempty"`
+	Toggle   keyBinding `json:"toggle,omitempty"`
+	AddBelow keyBinding `json:"add_below,omitempty"`
+	AddAbove keyBinding `json:"add_above,omitempty"`
+	Delete   keyBinding `json:"delete,omitempty"`
+	StopAll  keyBinding `json:"stop_all,omitempty"`
+	Continue keyBinding `json:"continue,omitempty"`
+	Quit     keyBinding `json:"quit,omitempty"`
+	Confirm  keyBinding `json:"confirm,omitempty"`
+}
+
+// DefaultKeymap returns the bindings urd has always had.
+func DefaultKeymap() Keymap {
+	return Keymap{
+		Up:       keyBinding{"k", "up", "ctrl+k"},
+		Down:     keyBinding{"j", "down", "ctrl+j"},
+		Toggle:   keyBinding{"enter", " "},
+		AddBelow: keyBinding{"o"},
+		AddAbove: keyBinding{"O"},
+		Delete:   keyBinding{"d"},
+		StopAll:  keyBinding{"s"},
+		Continue: keyBinding{"c"},
+		Quit:     keyBinding{"q", "ctrl+c"},
+		Confirm:  keyBinding{"y"},
+	}
+}
+
+// bindings lists every action with its name in the config file, so merging
+// and validation don't need a case per field.
+func (k *Keymap) bindings() []struct {
+	name string
+	keys *keyBinding
+} {
+	return []struct {
+		name string
+		keys *keyBinding
+	}{
+		{"up", &k.Up}, {"down", &k.Down}, {"toggle", &k.Toggle},
+		{"add_below", &k.AddBelow}, {"add_above", &k.AddAbove},
+		{"delete", &k.Delete}, {"stop_all", &k.StopAll},
+		{"continue", &k.Continue}, {"quit", &k.Quit}, {"confirm", &k.Confirm},
+	}
+}
+
+// withOverrides returns k with every action set in o replaced by o's keys.
+func (k Keymap) withOverrides(o Keymap) Keymap {
+	over := o.bindings()
+	for i, b := range k.bindings() {
+		if *over[i].keys != nil {
+			*b.keys = slices.Clone(*over[i].keys)
+		}
+	}
+	return k
+}
+
+// validate rejects empty key names and a key bound to two list actions,
+// which would make one of them unreachable. Confirm only applies inside
+// prompts, where the list keys don't, so it may share a key with them.
+func (k Keymap) validate() error {
+	owner := make(map[string]string)
+	for _, b := range k.bindings() {
+		for _, key := range *b.keys {
+			if strings.TrimSpace(key) == "" && key != " " {
+				return fmt.Errorf("keys.%s: empty key", b.name)
+			}
+			if b.name == "confirm" {
+				continue
+			}
+			if prev, ok := owner[key]; ok {
+				return fmt.Errorf("key %q is bound to both %s and %s", key, prev, b.name)
+			}
+			owner[key] = b.name
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestKeymapOverridesReplaceDefaults(t *testing.T) {
+	k := DefaultKeymap().withOverrides(Keymap{Toggle: keyBinding{"x"}})
+	if !k.Toggle.has("x") || k.Toggle.has("enter") {
+		t.Fatalf("expected toggle replaced by x, got %v", k.Toggle)
+	}
+	if !k.Down.has("j") {
+		t.Fatal("expected actions that aren't overridden to keep their defaults")
+	}
+	if err := k.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultKeymap().withOverrides(Keymap{Up: keyBinding{"j"}}).validate(); err == nil {
+		t.Fatal("expected j bound to both up and down to be rejected")
+	}
+}
+
+func TestRemappedKeys(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	m := initialModel(s)
+	m.keys = m.keys.withOverrides(Keymap{
+		Toggle:  keyBinding{"x"},
+		Down:    keyBinding{"down"},
+		Delete:  keyBinding{"D"},
+		Confirm: keyBinding{"Y"},
+	})
+
+	m = pressKeys(m, "j")
+	if m.cursor != 0 {
+		t.Fatal("expected j to stop moving down once down is remapped")
+	}
+	m = pressKeys(m, "enter")
+	if s.HasActive() {
+		t.Fatal("expected enter to stop toggling once toggle is remapped")
+	}
+	m = pressKeys(m, "x")
+	if !s.Streams[0].Active {
+		t.Fatal("expected x to toggle")
+	}
+	if !strings.Contains(m.View(), "x toggle") {
+		t.Fatal("expected the help line to show the remapped key")
+	}
+
+	m = pressKeys(m, "D", "D")
+	if !strings.Contains(m.View(), "(Y/n)") {
+		t.Fatal("expected the prompt to show the remapped confirm key")
+	}
+	m = pressKeys(m, "y")
+	if len(s.Streams) != 2 {
+		t.Fatal("expected y not to confirm once confirm is remapped")
+	}
+	pressKeys(m, "D", "D", "Y")
+	if len(s.Streams) != 1 {
+		t.Fatal("expected DD then Y to delete")
+	}
+}
+
+func TestRemappedKeysInTrashView(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.DeleteStream(s.Streams[1].ID)
+	s.DeleteStream(s.Streams[0].ID)
+	m := initialModel(s)
+	m.keys = m.keys.withOverrides(Keymap{
+		Down: keyBinding{"n"},
+		Quit: keyBinding{"x"},
+	})
+
+	m = pressKeys(m, "Z", "j")
+	if m.trashCursor != 0 {
+		t.Fatal("expected j to stop moving down once down is remapped")
+	}
+	m = pressKeys(m, "n")
+	if m.trashCursor != 1 {
+		t.Fatal("expected n to move down the trash")
+	}
+	if !strings.Contains(m.View(), "n/k navigate") || !strings.Contains(m.View(), "x quit") {
+		t.Fatal("expected the footer to show the remapped keys")
+	}
+
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); cmd != nil {
+		t.Fatal("expected q not to quit once quit is remapped")
+	}
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if cmd == nil {
+		t.Fatal("expected x to quit")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Fatal("expected x to quit")
+	}
+}
+
+func TestRemappedDeleteInSessionView(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	if err := s.AddPastSession(s.Streams[0].ID, time.Now().Add(-2*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(s)
+	m.keys = m.keys.withOverrides(Keymap{Delete: keyBinding{"D"}})
+
+	m = pressKeys(m, "v", "d", "d")
+	if m.confirmSessionDel {
+		t.Fatal("expected dd not to delete once delete is remapped")
+	}
+	if !strings.Contains(m.View(), "DD delete") {
+		t.Fatal("expected the footer to show the remapped delete key")
+	}
+	pressKeys(m, "D", "D", "y")
+	if len(s.Sessions) != 0 {
+		t.Fatal("expected DD then y to delete the session")
+	}
+}
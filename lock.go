@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/fjordengineering/urd/store"
+)
+
+// Lock is an advisory lock on the data file, held for as long as urd might
+// write to it. Each instance keeps the whole store in memory and saves it
+// wholesale, so two instances on the same file would silently overwrite
+// each other's changes. The lock is a sibling file (urd.json.lock) holding
+// the owner's PID, created with O_EXCL so only one process can win. A plain
+// file rather than flock keeps it portable, and the PID makes it
+// stale-tolerant: a lock left behind by a crashed process is taken over.
+type Lock struct {
+	path string
+}
+
+// LockedError reports that another running urd holds the lock.
+type LockedError struct {
+	Path string
+	PID  int
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("urd is already running (pid %d); if it isn't, remove %s", e.PID, e.Path)
+}
+
+// AcquireLock takes the lock for the data file at dataPath. Symlinks are
+// resolved first so every path to the same file shares one lock. If the
+// lock file names a process that no longer exists, or can't be read, it is
+// treated as stale and replaced.
+func AcquireLock(dataPath string) (*Lock, error) {
+	resolved, err := store.ResolveDataPath(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	path := resolved + ".lock"
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &Lock{path: path}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		pid := readLockPID(path)
+		if pid > 0 && processAlive(pid) {
+			return nil, &LockedError{Path: path, PID: pid}
+		}
+		// Stale. One retry is enough; if another instance grabbed it in
+		// the meantime, the second O_EXCL create reports that.
+		if attempt > 0 {
+			return nil, &LockedError{Path: path, PID: pid}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// Release removes the lock file. It is safe to call on a nil Lock, so
+// callers that only lock in some modes (not for --report) needn't check.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	err := os.Remove(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// readLockPID returns the PID recorded in a lock file, or 0 if it can't be
+// read or parsed.
+func readLockPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// processAlive reports whether pid is a running process. Signal 0 checks for
+// existence without delivering anything; EPERM means it exists but belongs
+// to another user.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
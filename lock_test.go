@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestAcquireLockAlreadyLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urd.json")
+	lock, err := AcquireLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = AcquireLock(path)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("expected LockedError, got %v", err)
+	}
+	if locked.PID != os.Getpid() {
+		t.Fatalf("expected the holder's pid, got %d", locked.PID)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("expected the lock to be free after release, got %v", err)
+	}
+	again.Release()
+}
+
+func TestAcquireLockTakesOverStaleLock(t *testing.T) {
+	// A finished child's PID stands in for a crashed urd.
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "urd.json")
+	os.WriteFile(path+".lock", []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0644)
+
+	lock, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("expected stale lock to be replaced, got %v", err)
+	}
+	defer lock.Release()
+	if got := readLockPID(path + ".lock"); got != os.Getpid() {
+		t.Fatalf("expected lock to record our pid, got %d", got)
+	}
+}
+
+func TestAcquireLockTakesOverGarbledLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urd.json")
+	os.WriteFile(path+".lock", []byte("not a pid"), 0644)
+	lock, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("expected unreadable lock to be treated as stale, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestReleaseNilLock(t *testing.T) {
+	var lock *Lock
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,23 +1,88 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/fjordengineering/urd/store"
+	"github.com/muesli/termenv"
 )
 
-var (
-	titleStyle  = lipgloss.NewStyle().Bold(true).MarginBottom(1)
-	cursorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
-	dotStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
-	helpStyle   = lipgloss.NewStyle().Faint(true).MarginTop(1)
-)
+// styles holds every lipgloss style the TUI renders with. They're built by
+// newStyles from one renderer rather than kept as package-level vars, so
+// color can be switched off in a single place. With color disabled the
+// renderer uses the ASCII profile, which drops bold and faint as well as
+// colors, so the output contains no escape codes at all; layout (margins,
+// borders) is unaffected.
+type styles struct {
+	renderer *lipgloss.Renderer
+	title    lipgloss.Style
+	cursor   lipgloss.Style
+	dot      lipgloss.Style
+	help     lipgloss.Style
+	faint    lipgloss.Style
+	err      lipgloss.Style
+	warn     lipgloss.Style
+	box      lipgloss.Style
+}
+
+// newStyles builds the TUI styles. With color on it uses lipgloss's default
+// renderer, so behavior on a TTY is exactly what it always was.
+func newStyles(color bool) styles {
+	r := lipgloss.DefaultRenderer()
+	if !color {
+		r = lipgloss.NewRenderer(os.Stdout)
+		r.SetColorProfile(termenv.Ascii)
+	}
+	return styles{
+		renderer: r,
+		title:    r.NewStyle().Bold(true).MarginBottom(1),
+		cursor:   r.NewStyle().Foreground(lipgloss.Color("6")),
+		dot:      r.NewStyle().Foreground(lipgloss.Color("1")),
+		help:     r.NewStyle().Faint(true).MarginTop(1),
+		faint:    r.NewStyle().Faint(true),
+		err:      r.NewStyle().Foreground(lipgloss.Color("1")),
+		warn:     r.NewStyle().Foreground(lipgloss.Color("1")).Bold(true),
+		// Box-drawn empty state gives visual weight to the onboarding hint,
+		// making the first-launch experience feel intentional rather than broken.
+		box: r.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("8")).
+			Padding(1, 2),
+	}
+}
+
+// name returns the style for a stream name in the given color.
+func (st styles) name(color string) lipgloss.Style {
+	return st.renderer.NewStyle().Foreground(lipgloss.Color(color))
+}
+
+// colorEnabled reports whether output should be styled: not when
+// --no-color is given or NO_COLOR is set to anything (https://no-color.org).
+func colorEnabled(noColor bool) bool {
+	return !noColor && os.Getenv("NO_COLOR") == ""
+}
+
+// streamColors is the palette "C" cycles through: no color, then the six
+// basic ANSI colors, which every terminal theme maps to something readable.
+var streamColors = []string{"", "1", "2", "3", "4", "5", "6"}
+
+// nextColor returns the palette entry after cur. A color set by hand in
+// urd.json that isn't in the palette cycles back to no color.
+func nextColor(cur string) string {
+	return streamColors[(slices.Index(streamColors, cur)+1)%len(streamColors)]
+}
 
 // tickMsg drives the 1-second UI refresh loop. We use tea.Tick (which
 // internally uses time.NewTimer) rather than a goroutine with time.Ticker
@@ -42,13 +107,78 @@ func tickCmd() tea.Cmd {
 // viewSessions toggles between the stream list (default) and the session list.
 // sessionCursor tracks the cursor position independently within the session
 // list so switching views preserves each cursor's position.
+// showArchived includes archived streams at the bottom of the list.
+// targetingID is set while the text input is collecting a target time.
+// pastSessionID is set while prompting for a past session on that stream
+// ("L"): first the duration, stored in pastSessionDur, then the start time.
+// notingID is set while the text input is collecting a note for a stream.
+// splittingID is set while prompting to split that stream ("|"): first the
+// new stream's name, stored in splitName, then how much time to move.
+// confirmReset is set while asking whether to zero the cursor stream ("R").
+// notice is a one-off warning shown above the footer until the next key,
+// e.g. that the data file was corrupt and had to be recovered.
+// quietQuit suppresses the post-exit summary (Q instead of q).
+// today switches the list and footer from lifetime totals to time tracked
+// since the current logical day began, which is DayStartHour rather than
+// midnight (see Store.DayStart; tab toggles it).
+// pctOfStreams switches the percentage column from the wall clock to the
+// streams' summed time ("%"); see percentBasis.
+// markedID is the merge target chosen with "m"; "M" merges the cursor
+// stream into it.
+// filter narrows the list to names containing it (case-insensitive);
+// filtering is true while the "/" prompt is open and editing it live.
+// renamingID is set while the text input is editing an existing stream's
+// name rather than naming a new one.
+// adjustingID is set while prompting for time to add to or take off that
+// stream ("+"); see updateAdjusting.
+// viewTrash shows deleted streams for recovery; trashCursor is its cursor.
+// historyID is the stream whose history ("h") is open, historyCursor the
+// selected row in it.
+// lastTick is when the previous tick fired. A gap longer than idleAfter
+// means the machine slept or the process was suspended; see checkIdle.
+// notifyAfter (0 = off) and notified drive the long-run reminders; see
+// dueNotifications.
+// staleSince is set when the TUI opens on streams that have gone unsaved
+// for suspiciously long (see Store.StaleSince); it holds the last save time
+// while asking whether to stop them as of then.
+// events is the --events-out writer (nil when off); eventsSeen counts the
+// store events already sent to it and lastSummary is when the last "tick"
+// line went out. See publishEvents.
+// pomodoroID is the stream running a pomodoro ("P"), and pomodoroEnd when
+// it's due to stop; see checkPomodoro.
+// keys holds the rebindable key bindings; see Keymap.
+// showHelp replaces the list with the key overlay ("?"); helpOffset is how
+// far it's scrolled. See help.go.
+// styles is built once by newStyles; see colorEnabled.
+// animate makes the active dot pulse: beat flips on every tick, and
+// activeDot shows ○ instead of ● while it is set. --no-animation turns it
+// off for screen readers, which would otherwise announce every flip.
+// undo is a stack of store snapshots taken before destructive actions, most
+// recent last, capped at maxUndo entries.
 type model struct {
-	store        *Store
+	store        *store.Store
 	cursor       int
 	adding       bool
 	addAbove     bool
+	renamingID   string
+	adjustingID  string
+	showArchived bool
+	targetingID  string
+	markedID     string
+	today        bool
+	pctOfStreams bool
+	quietQuit    bool
+	notice       string
+	notingID     string
+	splittingID  string
+	splitName    string
+	pastSessionID  string
+	pastSessionDur time.Duration
+	filter       string
+	filtering    bool
 	pendingD     bool
 	confirmDel   bool
+	confirmReset bool
 	startingAt       bool
 	startingAtID     string
 	startErr         string
@@ -60,23 +190,85 @@ type model struct {
 	confirmSessionDel   bool
 	editingSession      bool
 	editingSessionStart *time.Time
+	viewTrash           bool
+	historyID           string
+	historyCursor       int
+	trashCursor         int
+	undo                []*store.Store
+	lastTick            time.Time
+	idleAfter           time.Duration
+	notifyAfter         time.Duration
+	pomodoroID          string
+	pomodoroEnd         time.Time
+	notified            map[runKey]int
+	staleSince          *time.Time
+	events              *eventWriter
+	eventsSeen          int
+	lastSummary         time.Time
 	textinput    textinput.Model
+	keys         Keymap
+	showHelp     bool
+	helpOffset   int
+	styles       styles
+	animate      bool
+	beat         bool
 	ticking      bool
 	width        int
 	height       int
 }
 
-func initialModel(store *Store) model {
+// activeDot is the live-tracking indicator. It alternates between ● and ○
+// with the once-a-second tick, a heartbeat that shows the clock is running.
+// The tick only runs while something is active, so an idle screen isn't
+// redrawn just to animate.
+func (m model) activeDot() string {
+	if m.beat {
+		return m.styles.dot.Render("○")
+	}
+	return m.styles.dot.Render("●")
+}
+
+// The text input is shared by every prompt. Names are kept short so rows
+// line up; notes get more room and put the name limit back when they close.
+const (
+	nameCharLimit = 40
+	noteCharLimit = 200
+)
+
+func initialModel(s *store.Store) model {
 	ti := textinput.New()
 	ti.Placeholder = "Stream name"
-	ti.CharLimit = 40
+	ti.CharLimit = nameCharLimit
 
-	store.SortStreams()
-	return model{
-		store:     store,
+	s.SortStreams()
+	m := model{
+		store:     s,
 		textinput: ti,
-		ticking:   store.HasActive(),
+		ticking:   s.HasActive(),
+		idleAfter: defaultIdleAfter,
+		styles:    newStyles(true),
+		animate:   true,
+		keys:      DefaultKeymap(),
+		notified:  make(map[runKey]int),
+	}
+	var warnings []string
+	if s.Recovery != nil {
+		warnings = append(warnings, s.Recovery.String())
+	}
+	warnings = append(warnings, s.Repairs...)
+	if len(warnings) > 0 {
+		m.notice = "Warning: " + strings.Join(warnings, "; ")
 	}
+	if t, ok := s.StaleSince(time.Now()); ok {
+		m.staleSince = &t
+	}
+	// Assume the last known terminal size until the real one arrives, so
+	// the footer doesn't jump on the first frame.
+	if s.Window != nil {
+		m.width = s.Window.Width
+		m.height = s.Window.Height
+	}
+	return m
 }
 
 func (m model) Init() tea.Cmd {
@@ -86,22 +278,94 @@ func (m model) Init() tea.Cmd {
 	return nil
 }
 
+// Update handles msg, then passes whatever it changed on to --events-out.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if nm, ok := next.(model); ok && nm.events != nil {
+		nm.publishEvents(msg)
+		return nm, cmd
+	}
+	return next, cmd
+}
+
+// publishEvents sends a line for every event the store logged since the
+// last call. Every state change goes through the event log, so diffing it
+// catches them all without hooking each key. While streams run, a "tick"
+// line with the current totals also goes out once a minute.
+func (m *model) publishEvents(msg tea.Msg) {
+	for _, e := range m.store.Events[min(m.eventsSeen, len(m.store.Events)):] {
+		m.events.send(newEventLine(m.store, e))
+	}
+	m.eventsSeen = len(m.store.Events)
+	if t, ok := msg.(tickMsg); ok && m.store.HasActive() && time.Time(t).Sub(m.lastSummary) >= time.Minute {
+		m.lastSummary = time.Time(t)
+		m.events.send(newEventLine(m.store, store.Event{At: time.Time(t), Type: "tick"}))
+	}
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		// Persisted with the next Save; resizing alone isn't worth a write.
+		m.store.Window = &store.WindowSize{Width: msg.Width, Height: msg.Height}
 		return m, nil
 
 	case tickMsg:
+		if m.checkIdle(time.Time(msg)) {
+			m.sortAndFollow()
+			m.store.Save()
+		}
+		var cmds []tea.Cmd
+		if name, done := m.checkPomodoro(time.Time(msg)); done {
+			m.sortAndFollow()
+			m.store.Save()
+			m.notice = fmt.Sprintf("Pomodoro done: %s stopped. Take a %d-minute break, then P to start another.", name, int(pomodoroBreak.Minutes()))
+			cmds = append(cmds, notifyCmd("urd", fmt.Sprintf("Pomodoro done on %s. Time for a break.", name)))
+		}
 		if m.store.HasActive() {
+			if m.store.EnforceSessionCap(time.Time(msg)) {
+				m.store.Save()
+			}
 			m.sortAndFollow()
-			return m, tickCmd()
+			m.lastTick = time.Time(msg)
+			m.beat = m.animate && !m.beat
+			cmds = append(cmds, tickCmd())
+			for _, body := range m.dueNotifications(time.Time(msg)) {
+				cmds = append(cmds, notifyCmd("urd", body))
+			}
+			return m, tea.Batch(cmds...)
 		}
 		m.ticking = false
-		return m, nil
+		m.lastTick = time.Time{}
+		m.beat = false
+		return m, tea.Batch(cmds...)
 
 	case tea.KeyMsg:
+		// Keys typed blind could toggle or delete the wrong stream, so only
+		// quitting works until the window is big enough to see the list.
+		if m.tooSmall() {
+			if key := msg.String(); key == "Q" || m.keys.Quit.has(key) {
+				m.store.Save()
+				m.quietQuit = key == "Q"
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		m.notice = ""
+		if m.staleSince != nil {
+			return m.updateConfirmStale(msg)
+		}
+		if m.showHelp {
+			return m.updateHelp(msg)
+		}
+		if m.viewTrash {
+			return m.updateTrashView(msg)
+		}
+		if m.historyID != "" {
+			return m.updateHistoryView(msg)
+		}
 		if m.viewSessions {
 			if m.confirmSessionDel {
 				return m.updateConfirmSessionDel(msg)
@@ -114,21 +378,298 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.confirmDel {
 			return m.updateConfirmDel(msg)
 		}
+		if m.confirmReset {
+			return m.updateConfirmReset(msg)
+		}
 		if m.adding {
 			return m.updateAdding(msg)
 		}
+		if m.renamingID != "" {
+			return m.updateRenaming(msg)
+		}
+		if m.adjustingID != "" {
+			return m.updateAdjusting(msg)
+		}
+		if m.targetingID != "" {
+			return m.updateTargeting(msg)
+		}
+		if m.filtering {
+			return m.updateFiltering(msg)
+		}
 		if m.startingAt {
 			return m.updateStartingAt(msg)
 		}
 		if m.loggingPast {
 			return m.updateLoggingPast(msg)
 		}
+		if m.pastSessionID != "" {
+			return m.updatePastSession(msg)
+		}
+		if m.notingID != "" {
+			return m.updateNoting(msg)
+		}
+		if m.splittingID != "" {
+			return m.updateSplitting(msg)
+		}
 		return m.updateNormal(msg)
 	}
 
 	return m, nil
 }
 
+// maxUndo bounds the undo stack. Each snapshot is a full copy of the store,
+// so the history is kept short.
+const maxUndo = 10
+
+// pushUndo snapshots the store before a destructive action so "u" can put
+// it back.
+func (m *model) pushUndo() {
+	m.recordUndo(m.store.Clone())
+}
+
+// recordUndo adds snap, a Clone taken before an action that can fail, once
+// the action has succeeded. Pushing first and popping on failure would
+// lose the oldest snapshot whenever the stack was already full.
+func (m *model) recordUndo(snap *store.Store) {
+	m.undo = append(m.undo, snap)
+	if len(m.undo) > maxUndo {
+		m.undo = m.undo[len(m.undo)-maxUndo:]
+	}
+}
+
+// popUndo restores the most recent snapshot in place, so every holder of
+// the store pointer sees the restored state. Streams come back with their
+// original Active/StartedAt and the session that was open at snapshot time
+// is open again, so undoing a stop resumes tracking as if it never
+// happened. Returns false when there's nothing to undo.
+func (m *model) popUndo() bool {
+	if len(m.undo) == 0 {
+		return false
+	}
+	snap := m.undo[len(m.undo)-1]
+	m.undo = m.undo[:len(m.undo)-1]
+	// Runtime-only settings and the terminal size aren't part of the history.
+	snap.FilePath, snap.FileMode, snap.Window = m.store.FilePath, m.store.FileMode, m.store.Window
+	// The event log is append-only: the undone actions stay in it and the
+	// undo itself is recorded.
+	snap.Events = m.store.Events
+	*m.store = *snap
+	m.store.LogEvent(store.EventEdit, "", time.Now(), "undo")
+	return true
+}
+
+// visible returns the indices into m.store.Streams of the rows shown in the
+// list, in display order. Archived streams are hidden unless showArchived,
+// and a name filter (set with "/") narrows the list further. The cursor
+// always holds a real slice index, so m.store.Streams[m.cursor] stays valid
+// everywhere; only navigation and numbering go through this list.
+func (m *model) visible() []int {
+	query := strings.ToLower(m.filter)
+	var idx []int
+	for i, st := range m.store.Streams {
+		if st.Archived && !m.showArchived {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(st.Name), query) {
+			continue
+		}
+		idx = append(idx, i)
+	}
+	return idx
+}
+
+func (m *model) visibleCount() int {
+	return len(m.visible())
+}
+
+// elapsed is the time shown for a stream: lifetime, or since the start of
+// the day (midnight unless DayStartHour says otherwise) in today mode.
+func (m *model) elapsed(id string) time.Duration {
+	if m.today {
+		return m.store.ElapsedSince(id, m.store.DayStart(time.Now()))
+	}
+	return m.store.Elapsed(id)
+}
+
+// countedTotal sums elapsed over the streams that count toward the total,
+// and names the ones left out with "x". Streams running together each add
+// their full time, so unlike the wall clock this can exceed the time that
+// passed. Archived streams count, since the total covers everything.
+func (m *model) countedTotal() (time.Duration, []string) {
+	var sum time.Duration
+	var excluded []string
+	for _, st := range m.store.Streams {
+		if st.ExcludeFromTotal {
+			excluded = append(excluded, st.Name)
+			continue
+		}
+		sum += m.elapsed(st.ID)
+	}
+	return sum, excluded
+}
+
+// isEmpty reports whether st has never recorded any time, which makes it
+// safe to delete without asking and earns it the "(empty)" tag. A stream
+// whose counter was reset or that only ran before the anchor shows zero but
+// isn't empty: its history is still there.
+func (m *model) isEmpty(st store.Stream) bool {
+	return !st.Active && !m.store.HasRecordedTime(st.ID)
+}
+
+// percentBasis is what the percentage column divides by. By default it's
+// the wall clock, so streams that ran in parallel each get their share of
+// the time that passed and the column can add up to more than 100%. With
+// pctOfStreams it's the sum of every stream's time, archived ones
+// included, so the column splits 100% between them however much overlapped.
+// Comparing the two shows how much concurrent tracking inflates the totals.
+func (m *model) percentBasis() time.Duration {
+	if !m.pctOfStreams {
+		return m.wallClock()
+	}
+	var sum time.Duration
+	for _, st := range m.store.Streams {
+		sum += m.elapsed(st.ID)
+	}
+	return sum
+}
+
+// wallClock is the wall-clock total matching elapsed.
+func (m *model) wallClock() time.Duration {
+	if m.today {
+		return m.store.WallClockSince(m.store.DayStart(time.Now()))
+	}
+	return m.store.TotalWallClock()
+}
+
+// moveCursor steps the cursor delta rows through the visible list, wrapping
+// at either end.
+func (m *model) moveCursor(delta int) {
+	vis := m.visible()
+	if len(vis) == 0 {
+		return
+	}
+	pos := slices.Index(vis, m.cursor)
+	if pos < 0 {
+		m.cursor = vis[0]
+		return
+	}
+	m.cursor = vis[(pos+delta+len(vis))%len(vis)]
+}
+
+// clampCursor moves the cursor onto a visible stream after the list changes
+// underneath it (delete, archive, filter). It prefers the next visible row,
+// falling back to the last one.
+func (m *model) clampCursor() {
+	vis := m.visible()
+	if len(vis) == 0 {
+		m.cursor = 0
+		return
+	}
+	if slices.Contains(vis, m.cursor) {
+		return
+	}
+	for _, i := range vis {
+		if i >= m.cursor {
+			m.cursor = i
+			return
+		}
+	}
+	m.cursor = vis[len(vis)-1]
+}
+
+// defaultIdleAfter is how long a gap between ticks must be before it's
+// treated as idle time rather than work.
+const defaultIdleAfter = 30 * time.Minute
+
+// checkIdle detects a suspended machine. Ticks arrive every second while
+// streams are active, so a gap longer than idleAfter since the previous tick
+// means nothing was running the TUI — typically a laptop asleep. Everything
+// is stopped as of the last tick, so the session ends when activity actually
+// ceased rather than on wake-up, and the stopped set is left in LastActive
+// for a quick "c" to continue. lastTick is reset whenever the tick loop
+// stops, so an intentionally idle period with nothing running never counts.
+func (m *model) checkIdle(now time.Time) bool {
+	if m.idleAfter <= 0 || m.lastTick.IsZero() || !m.store.HasActive() {
+		return false
+	}
+	if now.Sub(m.lastTick) <= m.idleAfter {
+		return false
+	}
+	m.store.StopAllAt(m.lastTick)
+	m.lastTick = time.Time{}
+	return true
+}
+
+// pomodoroLength is one pomodoro's focus interval, and pomodoroBreak the
+// break suggested after it.
+const (
+	pomodoroLength = 25 * time.Minute
+	pomodoroBreak  = 5 * time.Minute
+)
+
+// checkPomodoro ends the running pomodoro once its interval is up, stopping
+// its stream as a plain stop would, so the focus time lands in the stream's
+// history like any other. It returns the stream's name when it did. A
+// pomodoro whose stream was stopped some other way (enter, stop all, focus
+// mode, undo) is dropped quietly: the timer only ever rides on the stream.
+func (m *model) checkPomodoro(now time.Time) (string, bool) {
+	if m.pomodoroID == "" {
+		return "", false
+	}
+	st := m.store.StreamByID(m.pomodoroID)
+	if st == nil || !st.Active {
+		m.pomodoroID = ""
+		return "", false
+	}
+	if now.Before(m.pomodoroEnd) {
+		return "", false
+	}
+	m.pomodoroID = ""
+	m.store.EnsureStopped(st.ID)
+	return st.Name, true
+}
+
+// runKey identifies one continuous run of a stream: the stream and the
+// moment it was started. Stopping and restarting makes a new run, which
+// resets its reminders.
+type runKey struct {
+	id    string
+	start time.Time
+}
+
+// dueNotifications returns a reminder for every active stream whose current
+// run has crossed another multiple of notifyAfter since the last check, and
+// records it so each threshold fires once per run. A run that is already
+// several thresholds in when first seen (urd was restarted mid-run) gets a
+// single reminder, not one per missed threshold. It measures the current
+// run rather than the stream's lifetime total: the point is to catch a
+// timer that was forgotten, not to celebrate a stream's history.
+func (m *model) dueNotifications(now time.Time) []string {
+	if m.notifyAfter <= 0 {
+		return nil
+	}
+	var due []string
+	seen := make(map[runKey]bool)
+	for _, st := range m.store.Streams {
+		if !st.Active || st.StartedAt == nil {
+			continue
+		}
+		key := runKey{st.ID, *st.StartedAt}
+		seen[key] = true
+		run := now.Sub(*st.StartedAt)
+		if n := int(run / m.notifyAfter); n > m.notified[key] {
+			m.notified[key] = n
+			due = append(due, fmt.Sprintf("%s has been running for %s", st.Name, formatHoursMinutes(run)))
+		}
+	}
+	for key := range m.notified {
+		if !seen[key] {
+			delete(m.notified, key)
+		}
+	}
+	return due
+}
+
 func (m *model) cursorID() string {
 	if len(m.store.Streams) == 0 || m.cursor >= len(m.store.Streams) {
 		return ""
@@ -143,6 +684,12 @@ func (m *model) cursorID() string {
 func (m *model) sortAndFollow() {
 	id := m.cursorID()
 	m.store.SortStreams()
+	m.follow(id)
+}
+
+// follow puts the cursor back on the stream with the given ID after the
+// slice was reordered.
+func (m *model) follow(id string) {
 	for i, s := range m.store.Streams {
 		if s.ID == id {
 			m.cursor = i
@@ -151,6 +698,26 @@ func (m *model) sortAndFollow() {
 	}
 }
 
+// jumpToBusiest moves the cursor to the first running stream in the list,
+// or to the one with the most time if nothing is running. It only looks at
+// visible rows, so it never lands on a filtered-out or hidden stream.
+func (m *model) jumpToBusiest() {
+	best, bestElapsed := -1, time.Duration(-1)
+	for _, i := range m.visible() {
+		st := m.store.Streams[i]
+		if st.Active {
+			m.cursor = i
+			return
+		}
+		if d := m.elapsed(st.ID); d > bestElapsed {
+			best, bestElapsed = i, d
+		}
+	}
+	if best >= 0 {
+		m.cursor = best
+	}
+}
+
 func (m model) updateAdding(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
@@ -163,7 +730,10 @@ func (m model) updateAdding(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if pos >= len(m.store.Streams) {
 				pos = len(m.store.Streams)
 			}
-			m.store.AddStream(name, pos)
+			if err := m.store.AddStream(name, pos); err != nil {
+				m.startErr = err.Error()
+				return m, nil
+			}
 			if pos >= len(m.store.Streams) {
 				pos = len(m.store.Streams) - 1
 			}
@@ -176,42 +746,77 @@ func (m model) updateAdding(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case "esc":
 		m.adding = false
+		m.startErr = ""
 		m.textinput.Reset()
 		return m, nil
 	}
+	m.startErr = ""
 	var cmd tea.Cmd
 	m.textinput, cmd = m.textinput.Update(msg)
 	return m, cmd
 }
 
-func (m model) updateStartingAt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+// updateRenaming handles the rename prompt opened with "e". Empty input is
+// treated like esc so a stream can never end up without a name.
+func (m model) updateRenaming(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		name := strings.TrimSpace(m.textinput.Value())
+		if name != "" {
+			snap := m.store.Clone()
+			if err := m.store.RenameStream(m.renamingID, name); err != nil {
+				m.startErr = err.Error()
+				return m, nil
+			}
+			m.recordUndo(snap)
+			m.store.Save()
+		}
+		m.renamingID = ""
+		m.textinput.Reset()
+		return m, nil
+	case "esc":
+		m.renamingID = ""
+		m.startErr = ""
+		m.textinput.Reset()
+		return m, nil
+	}
+	m.startErr = ""
+	var cmd tea.Cmd
+	m.textinput, cmd = m.textinput.Update(msg)
+	return m, cmd
+}
+
+// updateAdjusting handles the "+" prompt, which corrects the stream's time
+// by a signed amount such as "+15m" or "-1h" (see Store.EditSeconds).
+// Taking time off a running stream stops it, so the tick may end here.
+func (m model) updateAdjusting(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
 		input := strings.TrimSpace(m.textinput.Value())
 		if input == "" {
-			m.startingAt = false
+			m.adjustingID = ""
 			m.textinput.Reset()
 			return m, nil
 		}
-		startAt, err := parseStartTime(input)
+		d, err := parseAdjustment(input)
 		if err != nil {
 			m.startErr = err.Error()
 			return m, nil
 		}
-		m.store.ToggleStreamAt(m.startingAtID, startAt)
+		snap := m.store.Clone()
+		if err := m.store.EditSeconds(m.adjustingID, int64(d.Seconds())); err != nil {
+			m.startErr = err.Error()
+			return m, nil
+		}
+		m.recordUndo(snap)
 		m.sortAndFollow()
 		m.store.Save()
-		if !m.ticking && m.store.HasActive() {
-			m.ticking = true
-			m.startingAt = false
-			m.textinput.Reset()
-			return m, tickCmd()
-		}
-		m.startingAt = false
+		m.adjustingID = ""
+		m.startErr = ""
 		m.textinput.Reset()
 		return m, nil
 	case "esc":
-		m.startingAt = false
+		m.adjustingID = ""
 		m.startErr = ""
 		m.textinput.Reset()
 		return m, nil
@@ -222,74 +827,93 @@ func (m model) updateStartingAt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-// parseStartTime interprets the user's input as either minutes ago (plain
-// number) or an absolute HH:MM time (contains ':'). Returns the resolved
-// time.Time or an error for invalid input.
-func parseStartTime(input string) (time.Time, error) {
-	if strings.Contains(input, ":") {
-		t, err := time.Parse("15:04", input)
-		if err != nil {
-			return time.Time{}, fmt.Errorf("invalid time format, use HH:MM")
-		}
-		now := time.Now()
-		startAt := time.Date(now.Year(), now.Month(), now.Day(),
-			t.Hour(), t.Minute(), 0, 0, now.Location())
-		if startAt.After(now) {
-			return time.Time{}, fmt.Errorf("time is in the future")
-		}
-		return startAt, nil
+// parseAdjustment reads a time correction such as "+15m" or "-1h": a sign
+// and then minutes or a duration as for parseBlockDuration. Without a sign
+// the time is added.
+func parseAdjustment(input string) (time.Duration, error) {
+	sign := time.Duration(1)
+	if rest, ok := strings.CutPrefix(input, "-"); ok {
+		sign, input = -1, rest
+	} else {
+		input = strings.TrimPrefix(input, "+")
 	}
-	mins, err := strconv.Atoi(input)
-	if err != nil || mins < 0 {
-		return time.Time{}, fmt.Errorf("enter a number of minutes or HH:MM")
+	d, err := parseBlockDuration(strings.TrimSpace(input))
+	if err != nil {
+		return 0, fmt.Errorf("enter e.g. +15m or -1h")
 	}
-	return time.Now().Add(-time.Duration(mins) * time.Minute), nil
+	return sign * d, nil
 }
 
-// updateLoggingPast handles two-phase input for logging a completed past time
-// block. Phase 1 collects the start time; phase 2 collects the end time and
-// validates that end > start before committing the block via AddPastTime.
-func (m model) updateLoggingPast(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+// updateNoting handles the note prompt opened with "n". Each enter appends
+// one note; an empty enter just closes the prompt.
+func (m model) updateNoting(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if text := strings.TrimSpace(m.textinput.Value()); text != "" {
+			m.store.AddNote(m.notingID, text)
+			m.store.Save()
+		}
+		m.notingID = ""
+		m.textinput.Reset()
+		m.textinput.CharLimit = nameCharLimit
+		return m, nil
+	case "esc":
+		m.notingID = ""
+		m.textinput.Reset()
+		m.textinput.CharLimit = nameCharLimit
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.textinput, cmd = m.textinput.Update(msg)
+	return m, cmd
+}
+
+// updateSplitting handles the two prompts opened with "|": the new
+// stream's name, then the amount of time to move into it (see
+// Store.SplitStream). A taken name is caught at the first prompt so it can
+// be retyped; too large an amount is shown inline and the amount prompt
+// stays open.
+func (m model) updateSplitting(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
 		input := strings.TrimSpace(m.textinput.Value())
-		if input == "" {
-			m.loggingPast = false
-			m.loggingPastStart = nil
+		if m.splitName == "" {
+			if input == "" {
+				m.splittingID = ""
+				m.textinput.Reset()
+				return m, nil
+			}
+			if err := m.store.CheckName("", input); err != nil {
+				m.startErr = err.Error()
+				return m, nil
+			}
+			m.splitName = input
+			m.startErr = ""
 			m.textinput.Reset()
+			m.textinput.Placeholder = "Amount (minutes or e.g. 1h30m)"
 			return m, nil
 		}
-		parsed, err := parseStartTime(input)
+		dur, err := parseBlockDuration(input)
 		if err != nil {
 			m.startErr = err.Error()
 			return m, nil
 		}
-		if m.loggingPastStart == nil {
-			// Phase 1: start time collected, now ask for end time.
-			m.loggingPastStart = &parsed
-			m.startErr = ""
-			m.textinput.Reset()
-			m.textinput.Placeholder = "End time (minutes ago or HH:MM)"
-			return m, nil
-		}
-		// Phase 2: end time collected, validate and commit.
-		end := parsed
-		start := *m.loggingPastStart
-		if !end.After(start) {
-			m.startErr = "end time must be after start time"
+		snap := m.store.Clone()
+		if err := m.store.SplitStream(m.splittingID, m.splitName, int64(dur.Seconds())); err != nil {
+			m.startErr = err.Error()
 			return m, nil
 		}
-		m.store.AddPastTime(start, end)
+		m.recordUndo(snap)
 		m.sortAndFollow()
 		m.store.Save()
-		m.loggingPast = false
-		m.loggingPastStart = nil
+		m.splittingID = ""
+		m.splitName = ""
 		m.startErr = ""
 		m.textinput.Reset()
 		return m, nil
 	case "esc":
-		m.loggingPast = false
-		m.loggingPastStart = nil
+		m.splittingID = ""
+		m.splitName = ""
 		m.startErr = ""
 		m.textinput.Reset()
 		return m, nil
@@ -300,31 +924,312 @@ func (m model) updateLoggingPast(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-// updateSessionView handles navigation and actions within the session list.
-// j/k move the cursor, dd initiates deletion (with confirmation), enter starts
-// editing a session's times, and v/esc return to the stream view.
-func (m model) updateSessionView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	if msg.String() != "d" {
-		m.pendingSessionD = false
-	}
+// updateFiltering edits the filter live: every keystroke re-filters the
+// list. enter keeps the filter and returns to normal mode; esc clears it.
+func (m model) updateFiltering(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
-	case "q", "ctrl+c":
-		m.store.Save()
-		return m, tea.Quit
-
-	case "j", "down":
-		if len(m.store.Sessions) > 0 {
-			m.sessionCursor = (m.sessionCursor + 1) % len(m.store.Sessions)
-		}
+	case "enter":
+		m.filtering = false
+		m.textinput.Reset()
+		return m, nil
+	case "esc":
+		m.filtering = false
+		m.filter = ""
+		m.textinput.Reset()
+		m.clampCursor()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.textinput, cmd = m.textinput.Update(msg)
+	m.filter = strings.TrimSpace(m.textinput.Value())
+	m.clampCursor()
+	return m, cmd
+}
+
+// updateTargeting handles the target prompt opened with "g". Input is a Go
+// duration like "10h" or "1h30m", or a bare number of hours; "0" clears the
+// target.
+func (m model) updateTargeting(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		input := strings.TrimSpace(m.textinput.Value())
+		if input == "" {
+			m.targetingID = ""
+			m.textinput.Reset()
+			return m, nil
+		}
+		target, err := parseTarget(input)
+		if err != nil {
+			m.startErr = err.Error()
+			return m, nil
+		}
+		m.store.SetTarget(m.targetingID, target)
+		m.store.Save()
+		m.targetingID = ""
+		m.startErr = ""
+		m.textinput.Reset()
+		return m, nil
+	case "esc":
+		m.targetingID = ""
+		m.startErr = ""
+		m.textinput.Reset()
+		return m, nil
+	}
+	m.startErr = ""
+	var cmd tea.Cmd
+	m.textinput, cmd = m.textinput.Update(msg)
+	return m, cmd
+}
+
+// parseTarget accepts a Go duration ("10h", "90m", "1h30m") or a plain
+// number, which is read as hours since targets are usually whole hours.
+func parseTarget(input string) (time.Duration, error) {
+	if hours, err := strconv.ParseFloat(input, 64); err == nil {
+		if hours < 0 {
+			return 0, fmt.Errorf("target can't be negative")
+		}
+		return time.Duration(hours * float64(time.Hour)), nil
+	}
+	d, err := time.ParseDuration(input)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("enter a duration like 10h or 1h30m")
+	}
+	return d, nil
+}
+
+func (m model) updateStartingAt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		input := strings.TrimSpace(m.textinput.Value())
+		if input == "" {
+			m.startingAt = false
+			m.textinput.Reset()
+			return m, nil
+		}
+		startAt, err := parseStartTime(input)
+		if err != nil {
+			m.startErr = err.Error()
+			return m, nil
+		}
+		m.store.ToggleStreamAt(m.startingAtID, startAt)
+		m.sortAndFollow()
+		m.store.Save()
+		if !m.ticking && m.store.HasActive() {
+			m.ticking = true
+			m.startingAt = false
+			m.textinput.Reset()
+			return m, tickCmd()
+		}
+		m.startingAt = false
+		m.textinput.Reset()
+		return m, nil
+	case "esc":
+		m.startingAt = false
+		m.startErr = ""
+		m.textinput.Reset()
+		return m, nil
+	}
+	m.startErr = ""
+	var cmd tea.Cmd
+	m.textinput, cmd = m.textinput.Update(msg)
+	return m, cmd
+}
+
+// parseStartTime interprets the user's input as either minutes ago (plain
+// number) or an absolute HH:MM time (contains ':'). Returns the resolved
+// time.Time or an error for invalid input.
+func parseStartTime(input string) (time.Time, error) {
+	if strings.Contains(input, ":") {
+		t, err := time.Parse("15:04", input)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time format, use HH:MM")
+		}
+		now := time.Now()
+		startAt := time.Date(now.Year(), now.Month(), now.Day(),
+			t.Hour(), t.Minute(), 0, 0, now.Location())
+		if startAt.After(now) {
+			return time.Time{}, fmt.Errorf("time is in the future")
+		}
+		return startAt, nil
+	}
+	mins, err := strconv.Atoi(input)
+	if err != nil || mins < 0 {
+		return time.Time{}, fmt.Errorf("enter a number of minutes or HH:MM")
+	}
+	return time.Now().Add(-time.Duration(mins) * time.Minute), nil
+}
+
+// updateLoggingPast handles two-phase input for logging a completed past time
+// block. Phase 1 collects the start time; phase 2 collects the end time and
+// validates that end > start before committing the block via AddPastTime.
+func (m model) updateLoggingPast(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		input := strings.TrimSpace(m.textinput.Value())
+		if input == "" {
+			m.loggingPast = false
+			m.loggingPastStart = nil
+			m.textinput.Reset()
+			return m, nil
+		}
+		parsed, err := parseStartTime(input)
+		if err != nil {
+			m.startErr = err.Error()
+			return m, nil
+		}
+		if m.loggingPastStart == nil {
+			// Phase 1: start time collected, now ask for end time.
+			m.loggingPastStart = &parsed
+			m.startErr = ""
+			m.textinput.Reset()
+			m.textinput.Placeholder = "End time (minutes ago or HH:MM)"
+			return m, nil
+		}
+		// Phase 2: end time collected, validate and commit.
+		end := parsed
+		start := *m.loggingPastStart
+		if !end.After(start) {
+			m.startErr = "end time must be after start time"
+			return m, nil
+		}
+		m.store.AddPastTime(start, end)
+		m.sortAndFollow()
+		m.store.Save()
+		m.loggingPast = false
+		m.loggingPastStart = nil
+		m.startErr = ""
+		m.textinput.Reset()
+		return m, nil
+	case "esc":
+		m.loggingPast = false
+		m.loggingPastStart = nil
+		m.startErr = ""
+		m.textinput.Reset()
+		return m, nil
+	}
+	m.startErr = ""
+	var cmd tea.Cmd
+	m.textinput, cmd = m.textinput.Update(msg)
+	return m, cmd
+}
+
+// updatePastSession handles the two prompts opened with "L": a duration,
+// then an optional start time. Leaving the start empty places the block so
+// it ends now. Errors from AddPastSession (overlap, future) are shown inline
+// and the start prompt stays open to try another time.
+func (m model) updatePastSession(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		input := strings.TrimSpace(m.textinput.Value())
+		if m.pastSessionDur == 0 {
+			if input == "" {
+				m.pastSessionID = ""
+				m.textinput.Reset()
+				return m, nil
+			}
+			dur, err := parseBlockDuration(input)
+			if err != nil {
+				m.startErr = err.Error()
+				return m, nil
+			}
+			m.pastSessionDur = dur
+			m.startErr = ""
+			m.textinput.Reset()
+			m.textinput.Placeholder = "Start (HH:MM, YYYY-MM-DD HH:MM, minutes ago; empty = ending now)"
+			return m, nil
+		}
+		start, err := parsePastStart(input, m.pastSessionDur, time.Now())
+		if err != nil {
+			m.startErr = err.Error()
+			return m, nil
+		}
+		snap := m.store.Clone()
+		if err := m.store.AddPastSession(m.pastSessionID, start, m.pastSessionDur); err != nil {
+			m.startErr = err.Error()
+			return m, nil
+		}
+		m.recordUndo(snap)
+		m.store.Save()
+		m.pastSessionID = ""
+		m.pastSessionDur = 0
+		m.startErr = ""
+		m.textinput.Reset()
+		return m, nil
+	case "esc":
+		m.pastSessionID = ""
+		m.pastSessionDur = 0
+		m.startErr = ""
+		m.textinput.Reset()
+		return m, nil
+	}
+	m.startErr = ""
+	var cmd tea.Cmd
+	m.textinput, cmd = m.textinput.Update(msg)
+	return m, cmd
+}
+
+// parseBlockDuration reads the length of a past session: a Go duration
+// ("45m", "1h30m") or a plain number of minutes.
+func parseBlockDuration(input string) (time.Duration, error) {
+	if mins, err := strconv.Atoi(input); err == nil {
+		if mins <= 0 {
+			return 0, fmt.Errorf("duration must be positive")
+		}
+		return time.Duration(mins) * time.Minute, nil
+	}
+	d, err := time.ParseDuration(input)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("enter minutes or a duration like 1h30m")
+	}
+	return d, nil
+}
+
+// parsePastStart resolves the start of a past session. Empty means the
+// block ends at now; a full "YYYY-MM-DD HH:MM" reaches earlier days, which
+// is the usual case when reconstructing yesterday; anything else is read
+// by parseStartTime (today's HH:MM or minutes ago).
+func parsePastStart(input string, dur time.Duration, now time.Time) (time.Time, error) {
+	if input == "" {
+		return now.Add(-dur), nil
+	}
+	if strings.Contains(input, " ") {
+		t, err := time.ParseInLocation("2006-01-02 15:04", input, time.Local)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date, use YYYY-MM-DD HH:MM")
+		}
+		return t, nil
+	}
+	return parseStartTime(input)
+}
+
+// updateSessionView handles navigation and actions within the session list.
+// up/down (j/k by default) move the cursor, the delete key pressed twice
+// ("dd" by default) asks to delete the session, enter starts editing a
+// session's times, and v/esc return to the stream view.
+func (m model) updateSessionView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	if !m.keys.Delete.has(key) {
+		m.pendingSessionD = false
+	}
+	// Rebindable actions first, as in updateNormal.
+	switch {
+	case m.keys.Quit.has(key):
+		m.store.Save()
+		return m, tea.Quit
+
+	case m.keys.Down.has(key):
+		if len(m.store.Sessions) > 0 {
+			m.sessionCursor = (m.sessionCursor + 1) % len(m.store.Sessions)
+		}
 		return m, nil
 
-	case "k", "up":
+	case m.keys.Up.has(key):
 		if len(m.store.Sessions) > 0 {
 			m.sessionCursor = (m.sessionCursor - 1 + len(m.store.Sessions)) % len(m.store.Sessions)
 		}
 		return m, nil
 
-	case "d":
+	case m.keys.Delete.has(key):
 		if len(m.store.Sessions) == 0 {
 			return m, nil
 		}
@@ -336,7 +1241,9 @@ func (m model) updateSessionView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.pendingSessionD = false
 		m.confirmSessionDel = true
 		return m, nil
+	}
 
+	switch key {
 	case "enter":
 		if len(m.store.Sessions) == 0 {
 			return m, nil
@@ -358,13 +1265,93 @@ func (m model) updateSessionView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateTrashView handles the trash list: up/down move, enter restores the
+// selected stream, and Z/esc return to the stream view.
+func (m model) updateTrashView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.startErr = ""
+	key := msg.String()
+	switch {
+	case m.keys.Quit.has(key):
+		m.store.Save()
+		return m, tea.Quit
+
+	case m.keys.Down.has(key):
+		if len(m.store.Trash) > 0 {
+			m.trashCursor = (m.trashCursor + 1) % len(m.store.Trash)
+		}
+		return m, nil
+
+	case m.keys.Up.has(key):
+		if len(m.store.Trash) > 0 {
+			m.trashCursor = (m.trashCursor - 1 + len(m.store.Trash)) % len(m.store.Trash)
+		}
+		return m, nil
+	}
+
+	switch key {
+	case "enter":
+		if len(m.store.Trash) == 0 {
+			return m, nil
+		}
+		id := m.store.Trash[m.trashCursor].ID
+		if err := m.store.RestoreStream(id); err != nil {
+			m.startErr = err.Error()
+			return m, nil
+		}
+		m.store.SortStreams()
+		m.follow(id)
+		m.store.Save()
+		if m.trashCursor >= len(m.store.Trash) && m.trashCursor > 0 {
+			m.trashCursor--
+		}
+		return m, nil
+
+	case "Z", "esc":
+		m.viewTrash = false
+		return m, nil
+	}
+	return m, nil
+}
+
+// updateHistoryView handles keys in a stream's history. It is read-only:
+// runs are edited through their sessions in the session view.
+func (m model) updateHistoryView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	n := len(m.store.StreamHistory(m.historyID))
+	key := msg.String()
+	switch {
+	case m.keys.Quit.has(key):
+		m.store.Save()
+		return m, tea.Quit
+
+	case m.keys.Down.has(key):
+		if n > 0 {
+			m.historyCursor = (m.historyCursor + 1) % n
+		}
+		return m, nil
+
+	case m.keys.Up.has(key):
+		if n > 0 {
+			m.historyCursor = (m.historyCursor - 1 + n) % n
+		}
+		return m, nil
+	}
+
+	switch key {
+	case "h", "esc":
+		m.historyID = ""
+		return m, nil
+	}
+	return m, nil
+}
+
 // updateConfirmSessionDel handles the y/n confirmation prompt when deleting a
 // session. Deletion is always safe for validate() because removing a session
 // reduces wall-clock time, which can only make the invariant easier to satisfy.
 func (m model) updateConfirmSessionDel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "y":
+	switch {
+	case m.keys.Confirm.has(msg.String()):
 		m.confirmSessionDel = false
+		m.pushUndo()
 		m.store.DeleteSession(m.sessionCursor)
 		m.store.SortSessionsDesc()
 		m.store.Save()
@@ -411,6 +1398,7 @@ func (m model) updateEditingSession(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			// For ongoing sessions, skip end time phase — apply immediately.
 			if sess.End == nil {
 				newStart := *m.editingSessionStart
+				m.pushUndo()
 				if err := m.store.UpdateSession(m.sessionCursor, newStart, nil); err != nil {
 					m.startErr = "invalid: " + err.Error()
 					m.editingSessionStart = nil
@@ -456,6 +1444,7 @@ func (m model) updateEditingSession(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		m.pushUndo()
 		if err := m.store.UpdateSession(m.sessionCursor, newStart, newEnd); err != nil {
 			m.startErr = "invalid: " + err.Error()
 			return m, nil
@@ -482,44 +1471,43 @@ func (m model) updateEditingSession(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) updateNormal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	if msg.String() != "d" {
+	key := msg.String()
+	if !m.keys.Delete.has(key) {
 		m.pendingD = false
 	}
-	switch msg.String() {
-	case "q", "ctrl+c":
+	// Rebindable actions first (see Keymap), so a key bound to one of them
+	// wins over the fixed key below.
+	switch {
+	case m.keys.Quit.has(key):
 		// Save with streams still active so they resume on next launch.
 		// Quitting is not the same as stopping work.
 		m.store.Save()
 		return m, tea.Quit
 
-	case "j", "down", "ctrl+j":
-		if len(m.store.Streams) > 0 {
-			m.cursor = (m.cursor + 1) % len(m.store.Streams)
-		}
+	case m.keys.Down.has(key):
+		m.moveCursor(1)
 		m.pendingD = false
 		return m, nil
 
-	case "k", "up", "ctrl+k":
-		if len(m.store.Streams) > 0 {
-			m.cursor = (m.cursor - 1 + len(m.store.Streams)) % len(m.store.Streams)
-		}
+	case m.keys.Up.has(key):
+		m.moveCursor(-1)
 		m.pendingD = false
 		return m, nil
 
-	case "o":
+	case m.keys.AddBelow.has(key):
 		m.adding = true
 		m.addAbove = false
 		m.textinput.Focus()
 		return m, textinput.Blink
 
-	case "O":
+	case m.keys.AddAbove.has(key):
 		m.adding = true
 		m.addAbove = true
 		m.textinput.Focus()
 		return m, textinput.Blink
 
-	case "enter", " ":
-		if len(m.store.Streams) == 0 {
+	case m.keys.Toggle.has(key):
+		if m.visibleCount() == 0 {
 			return m, nil
 		}
 		m.store.ToggleStream(m.store.Streams[m.cursor].ID)
@@ -534,14 +1522,17 @@ func (m model) updateNormal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case "s":
+	case m.keys.StopAll.has(key):
+		if m.store.HasActive() {
+			m.pushUndo()
+		}
 		m.store.StopAll()
 		m.sortAndFollow()
 		m.store.Save()
 		m.ticking = false
 		return m, nil
 
-	case "c":
+	case m.keys.Continue.has(key):
 		m.store.ContinueAll()
 		m.sortAndFollow()
 		m.store.Save()
@@ -551,21 +1542,108 @@ func (m model) updateNormal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case "d":
+	case m.keys.Delete.has(key):
 		if !m.pendingD {
 			m.pendingD = true
 			return m, nil
 		}
-		// dd: delete
+		// dd: delete, asking first unless there's no time to lose.
 		m.pendingD = false
-		if len(m.store.Streams) == 0 {
+		if m.visibleCount() == 0 {
 			return m, nil
 		}
+		if m.isEmpty(m.store.Streams[m.cursor]) {
+			return m.performDelete()
+		}
 		m.confirmDel = true
 		return m, nil
+	}
+
+	switch key {
+	case "Q":
+		// Same as q, but without the summary printed after exit.
+		m.quietQuit = true
+		m.store.Save()
+		return m, tea.Quit
+
+	case "/":
+		m.filtering = true
+		m.textinput.Placeholder = "Filter streams"
+		m.textinput.SetValue(m.filter)
+		m.textinput.CursorEnd()
+		m.textinput.Focus()
+		return m, textinput.Blink
+
+	case "esc":
+		if m.filter != "" {
+			m.filter = ""
+			m.clampCursor()
+		}
+		return m, nil
+
+	case "g":
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.targetingID = m.store.Streams[m.cursor].ID
+		m.startErr = ""
+		m.textinput.Placeholder = "Target, e.g. 10h (0 clears)"
+		m.textinput.Focus()
+		return m, textinput.Blink
+
+	case "e":
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		stream := m.store.Streams[m.cursor]
+		m.renamingID = stream.ID
+		m.textinput.SetValue(stream.Name)
+		m.textinput.CursorEnd()
+		m.textinput.Focus()
+		return m, textinput.Blink
+
+	case "S":
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.pushUndo()
+		m.store.StopAllExcept(m.store.Streams[m.cursor].ID)
+		m.sortAndFollow()
+		m.store.Save()
+		if !m.store.HasActive() {
+			m.ticking = false
+		}
+		return m, nil
+
+	case "p":
+		// Pause/resume with its own memory, independent of s/c. With
+		// nothing running and a paused set remembered, p resumes;
+		// otherwise it pauses whatever is running.
+		if len(m.store.Paused) > 0 && !m.store.HasActive() {
+			m.store.Resume()
+		} else {
+			m.store.Pause()
+		}
+		m.sortAndFollow()
+		m.store.Save()
+		if !m.ticking && m.store.HasActive() {
+			m.ticking = true
+			return m, tickCmd()
+		}
+		if !m.store.HasActive() {
+			m.ticking = false
+		}
+		return m, nil
+
+	case "R":
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.confirmReset = true
+		return m, nil
 
 	case "t":
-		if len(m.store.Streams) == 0 {
+		if m.visibleCount() == 0 {
 			return m, nil
 		}
 		stream := m.store.Streams[m.cursor]
@@ -587,6 +1665,17 @@ func (m model) updateNormal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.textinput.Focus()
 		return m, textinput.Blink
 
+	case "+":
+		// Add time to the cursor stream or take some off.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.adjustingID = m.cursorID()
+		m.startErr = ""
+		m.textinput.Placeholder = "+15m or -1h"
+		m.textinput.Focus()
+		return m, textinput.Blink
+
 	case "T":
 		// Enter "log past time" input mode: two sequential prompts for
 		// start and end time. A closed session is added directly.
@@ -597,25 +1686,352 @@ func (m model) updateNormal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.textinput.Focus()
 		return m, textinput.Blink
 
+	case "L":
+		// Log a completed block of work on the cursor stream.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.pastSessionID = m.cursorID()
+		m.pastSessionDur = 0
+		m.startErr = ""
+		m.textinput.Placeholder = "Duration (minutes or e.g. 1h30m)"
+		m.textinput.Focus()
+		return m, textinput.Blink
+
+	case "|":
+		// Split some of the cursor stream's time off into a new stream.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.splittingID = m.cursorID()
+		m.splitName = ""
+		m.startErr = ""
+		m.textinput.Placeholder = "New stream name"
+		m.textinput.Focus()
+		return m, textinput.Blink
+
+	case "P":
+		// Start a pomodoro on the cursor stream, or end the running one
+		// early. Either way the stream is started or stopped as usual, and
+		// the tick loop does the counting down.
+		if m.pomodoroID != "" {
+			m.store.EnsureStopped(m.pomodoroID)
+			m.pomodoroID = ""
+			m.sortAndFollow()
+			m.store.Save()
+			return m, nil
+		}
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.pomodoroID = m.cursorID()
+		m.pomodoroEnd = time.Now().Add(pomodoroLength)
+		m.store.EnsureActive(m.pomodoroID)
+		m.sortAndFollow()
+		m.store.Save()
+		if !m.ticking {
+			m.ticking = true
+			return m, tickCmd()
+		}
+		return m, nil
+
+	case "A":
+		// Idempotent counterpart to enter: start the stream if it isn't
+		// running, otherwise do nothing.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.store.EnsureActive(m.store.Streams[m.cursor].ID)
+		m.sortAndFollow()
+		m.store.Save()
+		if !m.ticking && m.store.HasActive() {
+			m.ticking = true
+			return m, tickCmd()
+		}
+		return m, nil
+
+	case "X":
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.store.EnsureStopped(m.store.Streams[m.cursor].ID)
+		m.sortAndFollow()
+		m.store.Save()
+		if !m.store.HasActive() {
+			m.ticking = false
+		}
+		return m, nil
+
+	case "f":
+		// Switch to only this stream: stop everything else, keep the
+		// session running.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.pushUndo()
+		m.store.FocusStream(m.store.Streams[m.cursor].ID)
+		m.sortAndFollow()
+		m.store.Save()
+		if !m.ticking {
+			m.ticking = true
+			return m, tickCmd()
+		}
+		return m, nil
+
+	case "x":
+		// Keep the cursor stream (e.g. breaks) out of the footer total.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.pushUndo()
+		m.store.ToggleExcludeFromTotal(m.store.Streams[m.cursor].ID)
+		m.store.Save()
+		return m, nil
+
+	case "I":
+		// Mark (or unmark) the cursor stream as the interruption stream
+		// that picks up time between pausing one stream and starting the next.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.store.SetInterruptionStream(m.store.Streams[m.cursor].ID)
+		m.store.Save()
+		return m, nil
+
+	case "u":
+		if !m.popUndo() {
+			return m, nil
+		}
+		m.clampCursor()
+		m.sortAndFollow()
+		m.store.Save()
+		if !m.ticking && m.store.HasActive() {
+			m.ticking = true
+			return m, tickCmd()
+		}
+		return m, nil
+
+	case "a":
+		// Archive hides a finished stream without deleting its history;
+		// on an archived stream (visible via H) it brings it back.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		stream := m.store.Streams[m.cursor]
+		if stream.Archived {
+			m.store.UnarchiveStream(stream.ID)
+		} else {
+			m.store.ArchiveStream(stream.ID)
+		}
+		m.sortAndFollow()
+		m.clampCursor()
+		m.store.Save()
+		if !m.store.HasActive() {
+			m.ticking = false
+		}
+		return m, nil
+
+	case "tab":
+		m.today = !m.today
+		return m, nil
+
+	case "D":
+		// Delete without asking, for when dd's prompt is in the way. The
+		// stream still goes to the trash and u brings it back.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		return m.performDelete()
+
+	case "%":
+		m.pctOfStreams = !m.pctOfStreams
+		return m, nil
+
+	case "=":
+		id := m.cursorID()
+		m.store.NextSortMode()
+		m.follow(id)
+		m.store.Save()
+		return m, nil
+
+	case "F":
+		// Switching into focus mode can stop streams, so it's undoable
+		// like any other stop.
+		if m.store.Mode == store.ModeFocus {
+			m.store.SetMode("")
+		} else {
+			m.pushUndo()
+			m.store.SetMode(store.ModeFocus)
+		}
+		m.sortAndFollow()
+		m.store.Save()
+		return m, nil
+
+	case ".":
+		m.jumpToBusiest()
+		return m, nil
+
+	case "N":
+		// Start the totals over from now, or count everything again.
+		m.pushUndo()
+		if m.store.Anchor != nil {
+			m.store.SetAnchor(nil)
+		} else {
+			now := time.Now()
+			m.store.SetAnchor(&now)
+		}
+		m.sortAndFollow()
+		m.store.Save()
+		return m, nil
+
+	case "n":
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.notingID = m.cursorID()
+		m.textinput.Placeholder = "What did you do?"
+		m.textinput.CharLimit = noteCharLimit
+		m.textinput.Focus()
+		return m, textinput.Blink
+
+	case "C":
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		st := m.store.Streams[m.cursor]
+		m.store.SetColor(st.ID, nextColor(st.Color))
+		m.store.Save()
+		return m, nil
+
+	case "m":
+		// Mark the merge target; pressing m on it again clears the mark.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		if id := m.cursorID(); m.markedID == id {
+			m.markedID = ""
+		} else {
+			m.markedID = id
+		}
+		return m, nil
+
+	case "M":
+		// Merge the cursor stream into the marked one, e.g. to fold a
+		// duplicate "email" into "Email". Undoable with u.
+		src := m.cursorID()
+		if m.markedID == "" || src == "" || src == m.markedID {
+			return m, nil
+		}
+		snap := m.store.Clone()
+		if err := m.store.MergeStreams(src, m.markedID); err != nil {
+			m.markedID = ""
+			return m, nil
+		}
+		m.recordUndo(snap)
+		m.follow(m.markedID)
+		m.markedID = ""
+		m.sortAndFollow()
+		m.clampCursor()
+		m.store.Save()
+		if m.store.HasActive() && !m.ticking {
+			m.ticking = true
+			return m, tickCmd()
+		}
+		return m, nil
+
+	case "K", "J":
+		// Manual ordering: the first press pins the stream, further
+		// presses move it within the pinned block at the top.
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		delta := 1
+		if msg.String() == "K" {
+			delta = -1
+		}
+		id := m.cursorID()
+		m.store.MoveStream(id, delta)
+		m.follow(id)
+		m.store.Save()
+		return m, nil
+
+	case "H":
+		m.showArchived = !m.showArchived
+		m.clampCursor()
+		return m, nil
+
+	case "Z":
+		m.viewTrash = true
+		m.trashCursor = 0
+		return m, nil
+
+	case "h":
+		if m.visibleCount() == 0 {
+			return m, nil
+		}
+		m.historyID = m.cursorID()
+		m.historyCursor = 0
+		return m, nil
+
+	case "?":
+		m.showHelp = true
+		m.helpOffset = 0
+		return m, nil
+
 	case "v":
 		m.viewSessions = true
 		m.store.SortSessionsDesc()
 		m.sessionCursor = 0
 		return m, nil
 
-	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
-		n := int(msg.String()[0] - '1')
-		if n < len(m.store.Streams) {
-			m.cursor = n
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9", "0":
+		vis := m.visible()
+		if n, ok := jumpIndex(msg.String(), len(vis)); ok {
+			m.cursor = vis[n]
 		}
 		return m, nil
 	}
 	return m, nil
 }
 
+// jumpIndex maps a number key to a list position. Keys follow the keyboard's
+// top row, so "1" is the first stream and "0" — sitting after "9" — is the
+// tenth. Positions always refer to the list as rendered. ok is false when the
+// key is not a digit or the position is past the end of the list.
+func jumpIndex(key string, n int) (int, bool) {
+	if len(key) != 1 || key[0] < '0' || key[0] > '9' {
+		return 0, false
+	}
+	i := int(key[0] - '1')
+	if key == "0" {
+		i = 9
+	}
+	if i >= n {
+		return 0, false
+	}
+	return i, true
+}
+
+// updateConfirmStale answers the startup question about stale tracking.
+// "y" stops everything as of the last save, undoably and leaving the set in
+// LastActive for "c", exactly like idle detection; any other key keeps the
+// time, for a timer that really was left on on purpose.
+func (m model) updateConfirmStale(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	at := *m.staleSince
+	m.staleSince = nil
+	if !m.keys.Confirm.has(msg.String()) {
+		return m, nil
+	}
+	m.pushUndo()
+	m.store.StopAllAt(at)
+	m.sortAndFollow()
+	m.store.Save()
+	return m, nil
+}
+
 func (m model) updateConfirmDel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "y":
+	switch {
+	case m.keys.Confirm.has(msg.String()):
 		m.confirmDel = false
 		return m.performDelete()
 	default:
@@ -624,36 +2040,116 @@ func (m model) updateConfirmDel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 }
 
-// performDelete removes the stream at the cursor. If the stream was active,
-// we deactivate it first (without flushing — the time is being discarded with
-// the stream) and check whether that was the last active stream so we can
-// close the wall-clock session.
+// updateConfirmReset zeroes the cursor stream on "y". The reset can be
+// undone like any other edit.
+func (m model) updateConfirmReset(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.confirmReset = false
+	if !m.keys.Confirm.has(msg.String()) {
+		return m, nil
+	}
+	m.pushUndo()
+	m.store.ResetStream(m.store.Streams[m.cursor].ID)
+	m.sortAndFollow()
+	m.store.Save()
+	return m, nil
+}
+
+// performDelete removes the stream at the cursor. DeleteStream stops it
+// first if it was active; we check whether that was the last active stream
+// so we can close the wall-clock session.
 func (m model) performDelete() (tea.Model, tea.Cmd) {
-	if len(m.store.Streams) == 0 {
+	stream := m.store.StreamByID(m.cursorID())
+	if stream == nil {
 		return m, nil
 	}
-	stream := m.store.Streams[m.cursor]
+	m.pushUndo()
 	wasActive := stream.Active
-	if wasActive {
-		m.store.Streams[m.cursor].Active = false
-		m.store.Streams[m.cursor].StartedAt = nil
-	}
 	m.store.DeleteStream(stream.ID)
 	if wasActive && !m.store.HasActive() {
-		m.store.closeCurrentSession()
+		m.store.CloseCurrentSession()
 	}
 	m.store.SortStreams()
 	m.store.Save()
 	// Clamp cursor so it doesn't point past the end of the list.
-	if m.cursor >= len(m.store.Streams) && m.cursor > 0 {
-		m.cursor--
-	}
+	m.clampCursor()
 	if !m.store.HasActive() {
 		m.ticking = false
 	}
 	return m, nil
 }
 
+// percentOf expresses part as a percentage of whole, normally the wall
+// clock. It's shared by the stream list and the JSON report so both always
+// agree. Streams that ran in parallel can each approach 100%, so the column
+// can sum to more than 100% (see percentBasis for the alternative).
+func percentOf(part, whole time.Duration) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
+
+// maxBarWidth caps the proportion bars, and barReserve is the room kept for
+// the columns and markers around them. Bars only appear when at least
+// minBarWidth cells are left over.
+const (
+	maxBarWidth = 20
+	minBarWidth = 5
+	barReserve  = 70
+)
+
+// barWidth is how wide the proportion bars are for a terminal width cols
+// columns wide. It is 0, meaning no bars, before the first WindowSizeMsg and
+// on terminals too narrow to spare the room. Every row gets the same width
+// so the columns after the bar stay aligned.
+func barWidth(cols int) int {
+	w := min(cols-barReserve, maxBarWidth)
+	if w < minBarWidth {
+		return 0
+	}
+	return w
+}
+
+// barEighths are the partial block characters, one to seven eighths wide.
+var barEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// bar draws pct (0-100, from percentOf) as a horizontal bar width cells
+// wide, in eighth-cell steps, padded with spaces to the full width.
+func bar(pct float64, width int) string {
+	eighths := int(pct / 100 * float64(width*8))
+	eighths = max(0, min(eighths, width*8))
+	full, part := eighths/8, eighths%8
+	out := strings.Repeat("█", full) + barEighths[part]
+	cells := full
+	if part > 0 {
+		cells++
+	}
+	return out + strings.Repeat(" ", width-cells)
+}
+
+// formatAgo describes how long ago something happened in its largest
+// whole unit ("5m ago", "2h ago", "3d ago"), which is as precise as a
+// glance at the footer needs.
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// formatHoursMinutes is a compact formatDuration without seconds, used
+// where second-level precision is noise (targets and progress).
+func formatHoursMinutes(total time.Duration) string {
+	mins := int(total.Minutes())
+	return fmt.Sprintf("%dh %02dm", mins/60, mins%60)
+}
+
 func formatDuration(total time.Duration) string {
 	s := int(total.Seconds())
 	h := s / 3600
@@ -663,49 +2159,164 @@ func formatDuration(total time.Duration) string {
 }
 
 func (m model) View() string {
+	if m.tooSmall() {
+		return m.styles.warn.Render("Terminal too small") + "\n" +
+			m.styles.faint.Render(fmt.Sprintf("%dx%d, need %dx%d", m.width, m.height, minWidth, minHeight))
+	}
+	if m.showHelp {
+		return m.viewHelp()
+	}
+	if m.viewTrash {
+		return m.viewTrashList()
+	}
+	if m.historyID != "" {
+		return m.viewHistory()
+	}
 	if m.viewSessions {
 		return m.viewSessionList()
 	}
 
 	var b strings.Builder
 
-	b.WriteString(titleStyle.Render("urd - Time Tracker"))
+	title := "urd - Time Tracker"
+	if m.today {
+		title += " (today)"
+	}
+	if m.store.Mode == store.ModeFocus {
+		title += " (focus)"
+	}
+	if m.store.SortMode != store.SortActive {
+		title += " (by " + m.store.SortLabel() + ")"
+	}
+	if m.store.Anchor != nil {
+		title += " (since " + m.store.Anchor.Local().Format("2006-01-02 15:04") + ")"
+	}
+	if m.pctOfStreams {
+		title += " (% of stream time)"
+	}
+	b.WriteString(m.styles.title.Render(title))
 	b.WriteString("\n\n")
 
 	if len(m.store.Streams) == 0 && !m.adding {
-		// Box-drawn empty state gives visual weight to the onboarding hint,
-		// making the first-launch experience feel intentional rather than broken.
-		boxStyle := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("8")).
-			Padding(1, 2)
-		b.WriteString(boxStyle.Render("No streams yet.\nPress 'o' to start.") + "\n")
+		b.WriteString(m.styles.box.Render("No streams yet.\nPress 'o' to start.") + "\n")
 	}
 
-	for i, s := range m.store.Streams {
+	basis := m.percentBasis()
+	barCells := barWidth(m.width)
+	var rows []string
+	cursorRow := 0
+	for pos, i := range m.visible() {
+		s := m.store.Streams[i]
 		cursor := "  "
 		if i == m.cursor {
-			cursor = cursorStyle.Render("> ")
+			cursor = m.styles.cursor.Render("> ")
+			cursorRow = pos
 		}
 
-		num := lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf("%d ", i+1))
+		num := m.styles.faint.Render(fmt.Sprintf("%d ", pos+1))
 
-		line := fmt.Sprintf("%-20s", s.Name)
+		elapsed := m.elapsed(s.ID)
+		name := fmt.Sprintf("%-20s", s.Name)
+		if s.Color != "" {
+			name = m.styles.name(s.Color).Render(name)
+		}
+		pct := percentOf(elapsed, basis)
+		line := name + fmt.Sprintf("  %11s  %3.0f%%", formatDuration(elapsed), pct)
+		if barCells > 0 {
+			graph := bar(pct, barCells)
+			if s.Color != "" {
+				graph = m.styles.name(s.Color).Render(graph)
+			}
+			line += "  " + graph
+		}
+		// Targets are lifetime budgets, so progress ignores today mode.
+		lifetime := elapsed
+		if m.today {
+			lifetime = m.store.Elapsed(s.ID)
+		}
+		if frac, ok := s.TargetProgress(lifetime); ok {
+			target := time.Duration(s.TargetSeconds) * time.Second
+			line += m.styles.faint.Render(fmt.Sprintf("  %s / %s (%.0f%%)",
+				formatHoursMinutes(lifetime), formatHoursMinutes(target), frac*100))
+		}
+		if s.ID == m.store.InterruptionID {
+			line += m.styles.faint.Render(" ↯")
+		}
+		if s.ExcludeFromTotal {
+			line += m.styles.faint.Render(" (not in total)")
+		}
+		if m.isEmpty(s) {
+			line += m.styles.faint.Render(" (empty)")
+		}
+		if n := len(s.Notes); n > 0 {
+			line += m.styles.faint.Render(fmt.Sprintf(" ✎%d", n))
+		}
 		if s.Active {
-			line += "  " + dotStyle.Render("●")
+			line += "  " + m.activeDot()
+		}
+		if s.ID == m.markedID {
+			line += "  " + m.styles.cursor.Render("(merge into)")
+		}
+		if s.Pinned && !s.Archived {
+			line += "  " + m.styles.faint.Render("(pinned)")
+		}
+		if s.Archived {
+			line = m.styles.faint.Render(line + "  (archived)")
 		}
-		b.WriteString(cursor + num + line + "\n")
+		rows = append(rows, cursor+num+line)
+	}
+
+	// Everything below the rows is built separately so the list can be cut
+	// down to whatever space the header, prompts and footer leave.
+	head := b.String()
+	b.Reset()
+
+	if m.filter != "" && m.visibleCount() == 0 {
+		b.WriteString("  " + m.styles.faint.Render("No streams match.") + "\n")
+	}
+
+	if m.filtering {
+		b.WriteString("\n  / " + m.textinput.View() + "\n")
+	} else if m.filter != "" {
+		b.WriteString("\n  " + m.styles.faint.Render(fmt.Sprintf("Filter: %q (esc clears)", m.filter)) + "\n")
 	}
 
 	if m.adding {
 		b.WriteString("\n  " + m.textinput.View() + "\n")
+		if m.startErr != "" {
+			b.WriteString("  " + m.styles.err.Render(m.startErr) + "\n")
+		}
+	}
+
+	if m.notingID != "" {
+		b.WriteString("\n  Note: " + m.textinput.View() + "\n")
+	}
+
+	if m.renamingID != "" {
+		b.WriteString("\n  Rename: " + m.textinput.View() + "\n")
+		if m.startErr != "" {
+			b.WriteString("  " + m.styles.err.Render(m.startErr) + "\n")
+		}
+	}
+
+	if m.adjustingID != "" {
+		b.WriteString("\n  Adjust time: " + m.textinput.View() + "\n")
+		if m.startErr != "" {
+			b.WriteString("  " + m.styles.err.Render(m.startErr) + "\n")
+		}
+	}
+
+	if m.targetingID != "" {
+		b.WriteString("\n  Target: " + m.textinput.View() + "\n")
+		if m.startErr != "" {
+			b.WriteString("  " + m.styles.err.Render(m.startErr) + "\n")
+		}
 	}
 
 	if m.startingAt {
 		b.WriteString("\n  Start time: " + m.textinput.View() + "\n")
 		if m.startErr != "" {
-			errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
-			b.WriteString("  " + errStyle.Render(m.startErr) + "\n")
+			b.WriteString("  " + m.styles.err.Render(m.startErr) + "\n")
 		}
 	}
 
@@ -716,28 +2327,152 @@ func (m model) View() string {
 		}
 		b.WriteString("\n  " + label + m.textinput.View() + "\n")
 		if m.startErr != "" {
-			errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
-			b.WriteString("  " + errStyle.Render(m.startErr) + "\n")
+			b.WriteString("  " + m.styles.err.Render(m.startErr) + "\n")
+		}
+	}
+
+	if m.pastSessionID != "" {
+		label := "Log past session, duration: "
+		if m.pastSessionDur > 0 {
+			label = fmt.Sprintf("Log %s, start: ", formatHoursMinutes(m.pastSessionDur))
+		}
+		b.WriteString("\n  " + label + m.textinput.View() + "\n")
+		if m.startErr != "" {
+			b.WriteString("  " + m.styles.err.Render(m.startErr) + "\n")
+		}
+	}
+
+	if m.splittingID != "" {
+		label := "Split into new stream, name: "
+		if m.splitName != "" {
+			label = fmt.Sprintf("Move into %q, amount: ", m.splitName)
+		}
+		b.WriteString("\n  " + label + m.textinput.View() + "\n")
+		if m.startErr != "" {
+			b.WriteString("  " + m.styles.err.Render(m.startErr) + "\n")
 		}
 	}
 
 	if m.confirmDel {
-		warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
 		name := m.store.Streams[m.cursor].Name
-		b.WriteString("\n  " + warnStyle.Render(fmt.Sprintf("Delete \"%s\"? (y/n)", name)) + "\n")
+		b.WriteString("\n  " + m.styles.warn.Render(fmt.Sprintf("Delete \"%s\"? (%s/n)", name, m.keys.Confirm.help())) + "\n")
+	}
+	if m.confirmReset {
+		name := m.store.Streams[m.cursor].Name
+		b.WriteString("\n  " + m.styles.warn.Render(fmt.Sprintf("Reset \"%s\" to zero? (%s/n)", name, m.keys.Confirm.help())) + "\n")
+	}
+
+	if m.staleSince != nil {
+		b.WriteString("\n  " + m.styles.warn.Render(fmt.Sprintf(
+			"Streams are running but nothing was saved since %s (%s ago). Stop them as of then? (%s/n)",
+			m.staleSince.Format("2006-01-02 15:04"), formatHoursMinutes(time.Since(*m.staleSince)), m.keys.Confirm.help())) + "\n")
 	}
 
 	b.WriteString("\n")
 
-	total := m.store.TotalWallClock()
+	var footer strings.Builder
+	if m.notice != "" {
+		fmt.Fprintf(&footer, "  %s\n", m.styles.warn.Render(m.notice))
+	}
+	total := m.wallClock()
+	label := "Wall clock"
+	if m.today {
+		label = "Wall clock today"
+	}
 	if total > 0 || m.store.HasActive() {
-		dimStyle := lipgloss.NewStyle().Faint(true)
-		fmt.Fprintf(&b, "  %s\n", dimStyle.Render(fmt.Sprintf("Wall clock: %s", formatDuration(total))))
+		fmt.Fprintf(&footer, "  %s\n", m.styles.faint.Render(fmt.Sprintf("%s: %s", label, formatDuration(total))))
+		if st := m.store.StreamByID(m.pomodoroID); st != nil && st.Active {
+			left := max(time.Until(m.pomodoroEnd), 0).Round(time.Second)
+			fmt.Fprintf(&footer, "  %s\n", fmt.Sprintf("Pomodoro: %s, %d:%02d left (P to end)", st.Name, int(left.Minutes()), int(left.Seconds())%60))
+		}
+		if cur := m.store.CurrentSessionDuration(); cur > 0 {
+			fmt.Fprintf(&footer, "  %s\n", m.styles.faint.Render("This session: "+formatHoursMinutes(cur)))
+		}
+		if sum, excluded := m.countedTotal(); len(excluded) > 0 {
+			fmt.Fprintf(&footer, "  %s\n", m.styles.faint.Render(fmt.Sprintf("Total: %s (excluding %s)", formatDuration(sum), strings.Join(excluded, ", "))))
+		}
+	}
+	if len(m.store.Paused) > 0 && !m.store.HasActive() {
+		fmt.Fprintf(&footer, "  %s\n", m.styles.faint.Render(fmt.Sprintf("Paused (%d) — press p to resume", len(m.store.Paused))))
+	}
+	// While something runs the answer is always "now", so the line only
+	// shows when everything is stopped.
+	if last, ok := m.store.LastActivity(); ok && !m.store.HasActive() {
+		fmt.Fprintf(&footer, "  %s\n", m.styles.faint.Render("Last active: "+formatAgo(time.Since(last))))
+	}
+
+	k := m.keys
+	// Only the everyday keys; "?" lists the rest, grouped.
+	footer.WriteString(m.styles.help.Render(fmt.Sprintf("\n  ? all keys · %s toggle · %s/%s add below/above · %s%s delete · / filter · %s stop all · %s continue · u undo · %s/Q quit",
+		k.Toggle.help(), k.AddBelow.help(), k.AddAbove.help(), k.Delete.help(), k.Delete.help(), k.StopAll.help(), k.Continue.help(), k.Quit.help())))
+
+	rest := b.String()
+	var list strings.Builder
+	for _, row := range m.scrollRows(rows, cursorRow, m.height-lipgloss.Height(head+rest+footer.String())) {
+		list.WriteString(row + "\n")
+	}
+	return pinFooter(head+list.String()+rest, footer.String(), m.height)
+}
+
+// scrollRows fits the stream rows into space terminal rows, keeping the
+// cursor row in view. When they don't all fit, the list is shown a page at a
+// time with a "▲ N more" line above and "▼ N more" below (blank when there
+// is nothing on that side, so the list doesn't jump by a row as the cursor
+// moves). Paging rather than scrolling keeps this a pure function of the
+// cursor, with no offset to carry in the model; the last page is pulled up
+// to end on the last row so it's always full. Before the first
+// WindowSizeMsg (space unknown) everything is shown, and at least the
+// cursor row is shown however small the terminal is.
+func (m model) scrollRows(rows []string, cursor, space int) []string {
+	if m.height <= 0 || len(rows) <= space {
+		return rows
+	}
+	per := max(space-2, 1)
+	start := cursor / per * per
+	start = min(start, len(rows)-per)
+	end := start + per
+
+	above, below := "", ""
+	if start > 0 {
+		above = "  " + m.styles.faint.Render(fmt.Sprintf("▲ %d more", start))
+	}
+	if end < len(rows) {
+		below = "  " + m.styles.faint.Render(fmt.Sprintf("▼ %d more", len(rows)-end))
 	}
+	out := append([]string{above}, rows[start:end]...)
+	return append(out, below)
+}
+
+// The smallest terminal the layout fits in: a stream row with its name,
+// time and percentage, and enough lines for the title, one page of rows
+// and the footer.
+const (
+	minWidth  = 44
+	minHeight = 10
+)
+
+// tooSmall reports whether the terminal is below minWidth x minHeight. An
+// unknown size (before the first WindowSizeMsg) isn't too small.
+func (m model) tooSmall() bool {
+	return m.width > 0 && m.height > 0 && (m.width < minWidth || m.height < minHeight)
+}
 
-	b.WriteString(helpStyle.Render("\n  o/O add below/above · enter toggle · t timed start · T log past · dd delete · s stop all · c continue · v sessions · q quit"))
+// pinFooter joins body and footer, padding between them so the footer lands
+// on the terminal's bottom rows instead of floating just under a short list.
+func pinFooter(body, footer string, height int) string {
+	gap := footerGap(height, lipgloss.Height(body+footer))
+	return body + strings.Repeat("\n", gap) + footer
+}
 
-	return b.String()
+// footerGap returns how many blank rows to insert so that content using
+// `used` rows fills a terminal `height` rows tall. Before the first
+// WindowSizeMsg the height is 0 and we don't pad at all; content that is
+// already as tall as the terminal isn't padded either.
+func footerGap(height, used int) int {
+	if height <= 0 || used >= height {
+		return 0
+	}
+	return height - used
 }
 
 // viewSessionList renders the session list view. Each row shows the date,
@@ -746,18 +2481,17 @@ func (m model) View() string {
 func (m model) viewSessionList() string {
 	var b strings.Builder
 
-	b.WriteString(titleStyle.Render("urd - Sessions"))
+	b.WriteString(m.styles.title.Render("urd - Sessions"))
 	b.WriteString("\n\n")
 
 	if len(m.store.Sessions) == 0 {
-		dimStyle := lipgloss.NewStyle().Faint(true)
-		b.WriteString("  " + dimStyle.Render("No sessions recorded yet.") + "\n")
+		b.WriteString("  " + m.styles.faint.Render("No sessions recorded yet.") + "\n")
 	}
 
 	for i, sess := range m.store.Sessions {
 		cursor := "  "
 		if i == m.sessionCursor {
-			cursor = cursorStyle.Render("> ")
+			cursor = m.styles.cursor.Render("> ")
 		}
 
 		date := sess.Start.Format("2006-01-02")
@@ -771,12 +2505,12 @@ func (m model) viewSessionList() string {
 		if sess.End != nil {
 			dur = sess.End.Sub(sess.Start)
 		} else {
-			dur = time.Since(sess.Start).Truncate(time.Second)
+			dur = max(time.Since(sess.Start), 0).Truncate(time.Second)
 		}
 
 		line := fmt.Sprintf("%s  %s - %-5s   (%s)", date, startTime, endTime, formatDuration(dur))
 		if sess.End == nil {
-			line += "  " + dotStyle.Render("●")
+			line += "  " + m.activeDot()
 		}
 
 		b.WriteString(cursor + line + "\n")
@@ -789,38 +2523,421 @@ func (m model) viewSessionList() string {
 		}
 		b.WriteString("\n  " + label + m.textinput.View() + "\n")
 		if m.startErr != "" {
-			errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
-			b.WriteString("  " + errStyle.Render(m.startErr) + "\n")
+			b.WriteString("  " + m.styles.err.Render(m.startErr) + "\n")
 		}
 	}
 
 	if m.confirmSessionDel {
-		warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
 		sess := m.store.Sessions[m.sessionCursor]
-		b.WriteString("\n  " + warnStyle.Render(fmt.Sprintf(
-			"Delete session %s %s? (y/n)",
+		b.WriteString("\n  " + m.styles.warn.Render(fmt.Sprintf(
+			"Delete session %s %s? (%s/n)",
 			sess.Start.Format("2006-01-02"),
 			sess.Start.Format("15:04"),
+			m.keys.Confirm.help(),
 		)) + "\n")
 	}
 
-	b.WriteString(helpStyle.Render("\n  j/k navigate · dd delete · enter edit · v back · q quit"))
+	help := m.styles.help.Render(fmt.Sprintf("\n  %s/%s navigate · %s%s delete · enter edit · v back · %s quit",
+		m.keys.Down.help(), m.keys.Up.help(), m.keys.Delete.help(), m.keys.Delete.help(), m.keys.Quit.help()))
+
+	return pinFooter(b.String(), help, m.height)
+}
+
+// viewHistory renders every run of the stream opened with "h", newest
+// first like the session list: its date, start and end time (or "..." while
+// running) and duration, in local time. Long histories page with the same
+// scrolling as the main list.
+func (m model) viewHistory() string {
+	var name string
+	if st := m.store.StreamByID(m.historyID); st != nil {
+		name = st.Name
+	}
+
+	var b strings.Builder
+	b.WriteString(m.styles.title.Render("urd - History: " + name))
+	b.WriteString("\n\n")
+
+	runs := m.store.StreamHistory(m.historyID)
+	slices.Reverse(runs)
+	if len(runs) == 0 {
+		b.WriteString("  " + m.styles.faint.Render("No time recorded yet.") + "\n")
+	}
+
+	var rows []string
+	for i, r := range runs {
+		cursor := "  "
+		if i == m.historyCursor {
+			cursor = m.styles.cursor.Render("> ")
+		}
+		start := r.Start.Local()
+		endTime := "..."
+		var dur time.Duration
+		if r.End != nil {
+			endTime = r.End.Local().Format("15:04")
+			dur = r.End.Sub(r.Start)
+		} else {
+			dur = max(time.Since(r.Start), 0).Truncate(time.Second)
+		}
+		line := fmt.Sprintf("%s  %s - %-5s   (%s)", start.Format("2006-01-02"), start.Format("15:04"), endTime, formatDuration(dur))
+		if r.End == nil {
+			line += "  " + m.activeDot()
+		}
+		rows = append(rows, cursor+line)
+	}
+
+	footer := fmt.Sprintf("\n  %s\n", m.styles.faint.Render("Total: "+formatDuration(m.store.Elapsed(m.historyID))))
+	footer += m.styles.help.Render(fmt.Sprintf("\n  %s/%s navigate · h/esc back · %s quit",
+		m.keys.Down.help(), m.keys.Up.help(), m.keys.Quit.help()))
+
+	head := b.String()
+	var list strings.Builder
+	for _, row := range m.scrollRows(rows, m.historyCursor, m.height-lipgloss.Height(head+"\n"+footer)) {
+		list.WriteString(row + "\n")
+	}
+	return pinFooter(head+list.String()+"\n", footer, m.height)
+}
+
+// viewTrashList renders deleted streams with their deletion date. Entries
+// are purged automatically once they're older than the trash window.
+func (m model) viewTrashList() string {
+	var b strings.Builder
+
+	b.WriteString(m.styles.title.Render("urd - Trash"))
+	b.WriteString("\n\n")
+
+	if len(m.store.Trash) == 0 {
+		b.WriteString("  " + m.styles.faint.Render("Trash is empty.") + "\n")
+	}
+
+	for i, st := range m.store.Trash {
+		cursor := "  "
+		if i == m.trashCursor {
+			cursor = m.styles.cursor.Render("> ")
+		}
+		deleted := ""
+		if st.DeletedAt != nil {
+			deleted = m.styles.faint.Render("deleted " + st.DeletedAt.Format("2006-01-02 15:04"))
+		}
+		b.WriteString(cursor + fmt.Sprintf("%-20s", st.Name) + "  " + deleted + "\n")
+	}
+	if m.startErr != "" {
+		b.WriteString("\n  " + m.styles.err.Render(m.startErr) + "\n")
+	}
+
+	help := m.styles.help.Render(fmt.Sprintf("\n  %s/%s navigate · enter restore · Z back · %s quit",
+		m.keys.Down.help(), m.keys.Up.help(), m.keys.Quit.help()))
 
-	return b.String()
+	return pinFooter(b.String(), help, m.height)
 }
 
 func main() {
-	store, err := LoadStore("urd.json")
+	var opts runOptions
+	file := flag.String("file", "", "path to the data file (overrides $URD_FILE)")
+	flag.StringVar(&opts.fileMode, "file-mode", "", "permissions for the data file, e.g. 0600 (default 0644)")
+	flag.BoolVar(&opts.exportCSV, "export-csv", false, "print per-stream totals as CSV to stdout and exit")
+	flag.StringVar(&opts.report, "report", "", "print a report to stdout instead of starting the TUI (json, week, month)")
+	flag.StringVar(&opts.since, "since", "", "with --report, count only time from this date (YYYY-MM-DD) or instant (RFC3339)")
+	roundFlag := flag.Duration("round", 0, "with --report, round each stream's time up to this billing increment, e.g. 15m (default: rounding_minutes from the data file)")
+	flag.StringVar(&opts.until, "until", "", "with --report, count only time up to the end of this date (YYYY-MM-DD) or this instant (RFC3339)")
+	flag.BoolVar(&opts.status, "status", false, "print a one-line status for shell prompts (e.g. \"● Email 1h 02m\" or \"idle\") and exit")
+	flag.StringVar(&opts.jsonStream, "json-stream", "", "print one stream's time and state as JSON, by name or ID, and exit")
+	watch := flag.Bool("watch", false, "print a live, non-interactive view every second until interrupted")
+	flag.StringVar(&opts.anchor, "anchor", "", "count the TUI's totals from this point on: now, a date (YYYY-MM-DD), an RFC3339 instant, or off to count everything")
+	flag.StringVar(&opts.eventsOut, "events-out", "", "append a JSON line to this file or FIFO on every change while the TUI runs")
+	flag.BoolVar(&opts.noAnimation, "no-animation", false, "keep the active-stream dot steady instead of pulsing every second")
+	flag.BoolVar(&opts.noColor, "no-color", false, "render the TUI without colors or text styling (also honors $NO_COLOR)")
+	flag.BoolVar(&opts.focus, "focus", false, "switch to focus mode: starting a stream stops the others (F toggles it in the TUI)")
+	flag.StringVar(&opts.start, "start", "", "start the named stream, creating it if needed, and exit")
+	flag.StringVar(&opts.stop, "stop", "", "stop the named stream, or every running stream when no name is given, and exit")
+	flag.BoolVar(&opts.stopAll, "stop-all", false, "same as --stop without a name")
+	flag.BoolVar(&opts.repair, "repair", false, "fix sessions and spans that end before they start or name unknown streams, save, and report what changed")
+	flag.StringVar(&opts.importFile, "import", "", "create a stream for each line of this file (- for stdin) and exit")
+	flag.DurationVar(&opts.notifyAfter, "notify-after", 0, "send a desktop notification each time a stream has run this long without a break, e.g. 1h (0 disables)")
+	flag.DurationVar(&opts.idle, "idle", defaultIdleAfter, "stop tracking after a gap this long between ticks, e.g. on sleep (0 disables)")
+	flag.CommandLine.Parse(bareStop(os.Args[1:]))
+
+	// The config file fills in whatever wasn't given as a flag.
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["stop"] && strings.TrimSpace(opts.stop) == "" {
+		opts.stopAll = true
+	}
+	cfg, err := loadUserConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading data: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error reading config: %v\n", err)
 		os.Exit(1)
 	}
+	if !set["file-mode"] && cfg.FileMode != "" {
+		opts.fileMode = cfg.FileMode
+	}
+	if !set["idle"] && cfg.Idle != "" {
+		opts.idle = cfg.idle
+	}
+	if !set["notify-after"] && cfg.NotifyAfter != "" {
+		opts.notifyAfter = cfg.notifyAfter
+	}
+	if !set["no-color"] && cfg.NoColor {
+		opts.noColor = true
+	}
 
-	// WithAltScreen so the TUI doesn't pollute the user's scroll-back buffer
-	// — on exit, the terminal is restored to its previous state.
-	p := tea.NewProgram(initialModel(store), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	path, err := store.DataPath(*file, cfg.File)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating data file: %v\n", err)
+		os.Exit(1)
+	}
+	if *watch {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		if err := runWatch(path, cfg, os.Stdout, time.Second, stop); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Read-only runs don't need to wait for or block a running instance.
+	var lock *Lock
+	if !opts.readOnly() {
+		if lock, err = AcquireLock(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// -1 means --round wasn't given, so the file's rounding_minutes applies;
+	// an explicit --round 0 still turns rounding off.
+	opts.round = time.Duration(-1)
+	if set["round"] {
+		opts.round = max(*roundFlag, 0)
+	}
+	if (opts.since != "" || opts.until != "" || opts.round >= 0) && opts.report == "" {
+		fmt.Fprintln(os.Stderr, "Error: --since, --until and --round only apply to --report")
+		os.Exit(1)
+	}
+	err = run(path, cfg, opts)
+	lock.Release()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// bareStop lets --stop be given without a name. Go's flag package only
+// allows a missing value on boolean flags, so a --stop that comes last or
+// is followed by another flag is rewritten to --stop= before parsing, and
+// main treats the empty name as stopping everything.
+func bareStop(args []string) []string {
+	out := slices.Clone(args)
+	for i, arg := range out {
+		if arg == "--" {
+			break
+		}
+		if (arg == "-stop" || arg == "--stop") && (i+1 == len(out) || strings.HasPrefix(out[i+1], "-")) {
+			out[i] = arg + "="
+		}
+	}
+	return out
+}
+
+// loadUserConfig loads the config file from its default location.
+func loadUserConfig() (*Config, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return LoadConfig(path)
+}
+
+// runOptions holds the command-line flags run acts on, bound directly by
+// main's flag set and then topped up from the config file. round is -1
+// when --round wasn't given, so the data file's rounding_minutes applies.
+type runOptions struct {
+	fileMode    string
+	report      string
+	since       string
+	until       string
+	round       time.Duration
+	importFile  string
+	eventsOut   string
+	anchor      string
+	jsonStream  string
+	start       string
+	stop        string
+	stopAll     bool
+	exportCSV   bool
+	status      bool
+	repair      bool
+	noColor     bool
+	noAnimation bool
+	focus       bool
+	idle        time.Duration
+	notifyAfter time.Duration
+}
+
+// readOnly reports whether the run only reads the data file: reports,
+// exports, the status line and --json-stream. These skip the lock, so they
+// load with LoadStoreReadOnly and can never write over a running instance's
+// saves, not even to recover or migrate the file.
+func (o runOptions) readOnly() bool {
+	return o.report != "" || o.exportCSV || o.status || o.jsonStream != ""
+}
+
+// run is everything main does once the data file is located and locked.
+// It returns errors instead of exiting so main can release the lock on
+// every path.
+func run(path string, cfg *Config, opts runOptions) error {
+	load := store.LoadStore
+	if opts.readOnly() {
+		load = store.LoadStoreReadOnly
+	}
+	s, err := load(path)
+	if err != nil {
+		return fmt.Errorf("loading data: %w", err)
+	}
+	s.BackupCount = defaultBackups
+	cfg.apply(s)
+	if s.Recovery != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", s.Recovery)
+	}
+	// --repair reports load's own repairs with the rest.
+	if !opts.repair {
+		for _, r := range s.Repairs {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", r)
+		}
+	}
+	if opts.fileMode != "" {
+		mode, err := strconv.ParseUint(opts.fileMode, 8, 32)
+		if err != nil || mode > 0777 {
+			return fmt.Errorf("invalid --file-mode %q, use octal like 0600", opts.fileMode)
+		}
+		s.FileMode = os.FileMode(mode)
+	}
+
+	if opts.exportCSV {
+		return s.ExportCSV(os.Stdout)
+	}
+
+	if opts.report != "" {
+		var rng ReportRange
+		if opts.since != "" {
+			if rng.Since, err = parseReportBound(s, opts.since, false); err != nil {
+				return err
+			}
+		}
+		if opts.until != "" {
+			if rng.Until, err = parseReportBound(s, opts.until, true); err != nil {
+				return err
+			}
+		}
+		if !rng.Since.IsZero() && !rng.Until.IsZero() && !rng.Until.After(rng.Since) {
+			return fmt.Errorf("--until must not be before --since")
+		}
+		round := opts.round
+		if round < 0 {
+			round = time.Duration(s.RoundingMinutes) * time.Minute
+		}
+		return writeReport(os.Stdout, s, opts.report, rng, round)
+	}
+
+	if opts.status {
+		fmt.Println(statusLine(s))
+		return nil
+	}
+
+	if opts.jsonStream != "" {
+		return writeStreamJSON(os.Stdout, s, opts.jsonStream)
+	}
+
+	// The mode is saved with the data, so --focus only needs passing once.
+	if opts.focus {
+		s.SetMode(store.ModeFocus)
+	}
+	// So is the anchor.
+	if opts.anchor != "" {
+		at, err := parseAnchor(s, opts.anchor, time.Now())
+		if err != nil {
+			return err
+		}
+		s.SetAnchor(at)
+		if err := s.Save(); err != nil {
+			return fmt.Errorf("saving data: %w", err)
+		}
+	}
+
+	if opts.repair {
+		return runRepair(s, os.Stdout)
+	}
+
+	if opts.start != "" {
+		return runStart(s, opts.start, os.Stdout)
+	}
+	if opts.stopAll {
+		return runStopAll(s, os.Stdout)
+	}
+	if opts.stop != "" {
+		return runStop(s, opts.stop, os.Stdout)
+	}
+
+	if opts.importFile != "" {
+		return runImport(s, opts.importFile, os.Stdout)
+	}
+
+	if flag.NArg() > 0 {
+		return runCommand(s, flag.Args(), os.Stdout)
+	}
+
+	// WithAltScreen so the TUI doesn't pollute the user's scroll-back buffer
+	// — on exit, the terminal is restored to its previous state.
+	m := initialModel(s)
+	m.idleAfter = opts.idle
+	m.notifyAfter = opts.notifyAfter
+	m.animate = !opts.noAnimation
+	if cfg.Keys != nil {
+		m.keys = m.keys.withOverrides(*cfg.Keys)
+	}
+	if opts.eventsOut != "" {
+		m.events = newEventWriter(opts.eventsOut)
+		m.eventsSeen = len(s.Events)
+		defer m.events.Close(time.Second)
+	}
+	m.styles = newStyles(colorEnabled(opts.noColor))
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	final, err := p.Run()
+	if err != nil {
+		return err
+	}
+	if fm, ok := final.(model); ok && !fm.quietQuit {
+		writeQuitSummary(os.Stdout, s)
+	}
+	return nil
+}
+
+// parseAnchor parses an --anchor value: "now", "off" (nil, clearing the
+// anchor), or a date or instant as for --since.
+func parseAnchor(s *store.Store, value string, now time.Time) (*time.Time, error) {
+	switch value {
+	case "now":
+		return &now, nil
+	case "off":
+		return nil, nil
+	}
+	at, err := parseReportBound(s, value, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --anchor: %w", err)
+	}
+	return &at, nil
+}
+
+// writeQuitSummary prints the streams still being tracked after the TUI
+// exits. Quitting doesn't stop anything — active streams keep counting and
+// resume on the next launch — so this is a reminder that the clock is still
+// running, shown on the normal screen once the alt screen is gone. Nothing
+// is printed when no stream is active.
+func writeQuitSummary(w io.Writer, s *store.Store) {
+	if !s.HasActive() {
+		return
+	}
+	fmt.Fprintln(w, "Still tracking (resumes on next launch):")
+	for _, st := range s.ActiveStreams() {
+		fmt.Fprintf(w, "  ● %-20s  %11s\n", st.Name, formatDuration(s.Elapsed(st.ID)))
+	}
+}
@@ -0,0 +1,1186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fjordengineering/urd/store"
+	"github.com/muesli/termenv"
+)
+
+// newTestStore returns an empty store that saves into a temporary file.
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	return &store.Store{FilePath: filepath.Join(t.TempDir(), "urd.json")}
+}
+
+func TestJumpIndex(t *testing.T) {
+	tests := []struct {
+		key  string
+		n    int
+		want int
+		ok   bool
+	}{
+		{"1", 3, 0, true},
+		{"3", 3, 2, true},
+		{"4", 3, 0, false},
+		{"0", 12, 9, true},
+		{"0", 9, 0, false},
+		{"x", 12, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := jumpIndex(tt.key, tt.n)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("jumpIndex(%q, %d) = %d, %v; want %d, %v", tt.key, tt.n, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFooterGap(t *testing.T) {
+	tests := []struct {
+		height, used, want int
+	}{
+		{0, 10, 0},   // no WindowSizeMsg yet
+		{24, 10, 14}, // short list: pad to the bottom
+		{24, 24, 0},  // exact fit
+		{24, 40, 0},  // overflow: never negative
+		{1, 0, 1},
+	}
+	for _, tt := range tests {
+		if got := footerGap(tt.height, tt.used); got != tt.want {
+			t.Errorf("footerGap(%d, %d) = %d, want %d", tt.height, tt.used, got, tt.want)
+		}
+	}
+}
+
+func TestPinFooterFillsHeight(t *testing.T) {
+	body := "title\n\n  stream\n"
+	footer := "  help"
+	got := pinFooter(body, footer, 10)
+	if h := strings.Count(got, "\n") + 1; h != 10 {
+		t.Fatalf("expected 10 rows, got %d", h)
+	}
+	if !strings.HasSuffix(got, footer) {
+		t.Fatal("expected footer on the last row")
+	}
+	if pinFooter(body, footer, 0) != body+footer {
+		t.Fatal("expected no padding without a known height")
+	}
+}
+
+func TestLongListScrollsWithCursor(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 30; i++ {
+		s.AddStream(fmt.Sprintf("stream %02d", i), i)
+	}
+	m := initialModel(s)
+	m.height = 15
+	m.styles = newStyles(false)
+
+	view := m.View()
+	if h := strings.Count(view, "\n") + 1; h != 15 {
+		t.Fatalf("expected the view to fit 15 rows, got %d", h)
+	}
+	if !strings.Contains(view, "▼") || strings.Contains(view, "▲") {
+		t.Fatal("expected only a more-below indicator at the top of the list")
+	}
+	if !strings.Contains(view, "q/Q quit") {
+		t.Fatal("expected the help line to stay visible")
+	}
+
+	m.cursor = len(s.Streams) - 1
+	view = m.View()
+	if !strings.Contains(view, "> 30 "+s.Streams[m.cursor].Name) {
+		t.Fatalf("expected the cursor row in view:\n%s", view)
+	}
+	if !strings.Contains(view, "▲") || strings.Contains(view, "▼") {
+		t.Fatal("expected only a more-above indicator at the end of the list")
+	}
+	if h := strings.Count(view, "\n") + 1; h != 15 {
+		t.Fatalf("expected the view to fit 15 rows, got %d", h)
+	}
+}
+
+func TestInitialModelUsesPersistedWindowSize(t *testing.T) {
+	s := newTestStore(t)
+	m := initialModel(s)
+	if m.width != 0 || m.height != 0 {
+		t.Fatal("expected no size assumption without a persisted window")
+	}
+
+	s.Window = &store.WindowSize{Width: 120, Height: 40}
+	m = initialModel(s)
+	if m.width != 120 || m.height != 40 {
+		t.Fatalf("expected 120x40, got %dx%d", m.width, m.height)
+	}
+
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	if um := updated.(model); um.width != 80 || s.Window.Height != 24 {
+		t.Fatal("expected WindowSizeMsg to update model and persisted size")
+	}
+}
+
+func TestParseAdjustment(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+		ok    bool
+	}{
+		{"+15m", 15 * time.Minute, true},
+		{"-1h", -time.Hour, true},
+		{"30", 30 * time.Minute, true},
+		{"- 90", -90 * time.Minute, true},
+		{"+0", 0, false},
+		{"-x", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseAdjustment(tt.input)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseAdjustment(%q) = %s, %v; want %s", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestAdjustPromptAddsTime(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Work", 0)
+	m := pressKeys(initialModel(s), "+", "+", "1", "5", "m", "enter")
+	if m.adjustingID != "" || s.Elapsed(s.Streams[0].ID) != 15*time.Minute {
+		t.Fatalf("expected 15m added and the prompt closed, got %s", s.Elapsed(s.Streams[0].ID))
+	}
+	m = pressKeys(m, "u")
+	if s.Elapsed(s.Streams[0].ID) != 0 {
+		t.Fatal("expected u to take the correction back")
+	}
+}
+
+func TestRenameEscCancels(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Emial", 0)
+	m := initialModel(s)
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	m = updated.(model)
+	if m.textinput.Value() != "Emial" {
+		t.Fatalf("expected input pre-filled with name, got %q", m.textinput.Value())
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	updated, _ = updated.(model).Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	if m.renamingID != "" || s.Streams[0].Name != "Emial" {
+		t.Fatal("expected esc to cancel without renaming")
+	}
+}
+
+func pressKeys(m model, keys ...string) model {
+	for _, k := range keys {
+		var msg tea.KeyMsg
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	return m
+}
+
+func TestUndoDelete(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.ToggleStream(s.Streams[0].ID)
+	m := initialModel(s)
+
+	m = pressKeys(m, "d", "d", "y")
+	if len(s.Streams) != 1 || s.HasActive() {
+		t.Fatal("expected active stream deleted and session closed")
+	}
+
+	m = pressKeys(m, "u")
+	if len(s.Streams) != 2 {
+		t.Fatalf("expected 2 streams after undo, got %d", len(s.Streams))
+	}
+	if !s.HasActive() || s.Streams[0].StartedAt == nil {
+		t.Fatal("expected Active/StartedAt restored")
+	}
+	if len(s.Sessions) != 1 || s.Sessions[0].End != nil {
+		t.Fatal("expected the session to be open again")
+	}
+	if len(m.undo) != 0 {
+		t.Fatal("expected undo stack to be empty")
+	}
+}
+
+func TestUndoStopAllMultipleLevels(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.ToggleStream(s.Streams[0].ID)
+	s.ToggleStream(s.Streams[1].ID)
+	m := initialModel(s)
+
+	m = pressKeys(m, "S") // stop all but the cursor stream
+	m = pressKeys(m, "s") // stop the rest
+	if s.HasActive() {
+		t.Fatal("expected everything stopped")
+	}
+
+	m = pressKeys(m, "u")
+	if !s.Streams[0].Active || s.Streams[1].Active {
+		t.Fatal("expected one level of undo to restart only the kept stream")
+	}
+	m = pressKeys(m, "u")
+	if !s.Streams[0].Active || !s.Streams[1].Active {
+		t.Fatal("expected second undo to restore both streams")
+	}
+	m = pressKeys(m, "u") // nothing left: no-op
+	if !s.HasActive() {
+		t.Fatal("expected undo on an empty stack to change nothing")
+	}
+}
+
+func TestUndoStackBounded(t *testing.T) {
+	s := newTestStore(t)
+	m := initialModel(s)
+	for i := 0; i < maxUndo+5; i++ {
+		m.pushUndo()
+	}
+	if len(m.undo) != maxUndo {
+		t.Fatalf("expected %d snapshots, got %d", maxUndo, len(m.undo))
+	}
+}
+
+func TestFailedActionKeepsFullUndoStack(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	m := initialModel(s)
+	for i := 0; i < maxUndo; i++ {
+		m.pushUndo()
+	}
+	oldest := m.undo[0]
+	m = pressKeys(m, "e")
+	m.textinput.SetValue("b")
+	m = pressKeys(m, "enter")
+	if m.startErr == "" {
+		t.Fatal("expected renaming onto a taken name to fail")
+	}
+	if len(m.undo) != maxUndo || m.undo[0] != oldest {
+		t.Fatal("expected the failed rename to leave the undo history as it was")
+	}
+}
+
+func TestArchivedStreamsHiddenFromNavigation(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	m := initialModel(s)
+
+	m = pressKeys(m, "j", "a") // archive B
+	if m.visibleCount() != 1 || m.cursor != 0 {
+		t.Fatalf("expected one visible stream with cursor clamped, got %d/%d", m.visibleCount(), m.cursor)
+	}
+	m = pressKeys(m, "2")
+	if m.cursor != 0 {
+		t.Fatal("expected number keys to ignore hidden streams")
+	}
+	if strings.Contains(m.View(), "B") {
+		t.Fatal("expected archived stream hidden from the list")
+	}
+
+	m = pressKeys(m, "H", "2")
+	if m.cursor != 1 || !strings.Contains(m.View(), "(archived)") {
+		t.Fatal("expected H to reveal archived streams")
+	}
+}
+
+func TestIdleGapStopsAtLastTick(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	start := time.Now().Add(-3 * time.Hour)
+	s.ToggleStreamAt(s.Streams[0].ID, start)
+	m := initialModel(s)
+
+	lastTick := start.Add(time.Hour)
+	m.lastTick = lastTick
+	updated, _ := m.Update(tickMsg(time.Now()))
+	m = updated.(model)
+
+	if s.HasActive() || m.ticking {
+		t.Fatal("expected idle gap to stop tracking")
+	}
+	sess := s.Sessions[0]
+	if sess.End == nil || !sess.End.Equal(lastTick) {
+		t.Fatalf("expected session backdated to the last tick, got %v", sess.End)
+	}
+	if sp := sess.Spans[0]; sp.End == nil || !sp.End.Equal(lastTick) {
+		t.Fatal("expected span backdated to the last tick")
+	}
+	if len(s.LastActive) != 1 {
+		t.Fatal("expected stopped streams remembered for continue")
+	}
+}
+
+func TestShortTickGapIsNotIdle(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.ToggleStream(s.Streams[0].ID)
+	m := initialModel(s)
+	m.lastTick = time.Now().Add(-2 * time.Second)
+
+	updated, _ := m.Update(tickMsg(time.Now()))
+	if !s.HasActive() || updated.(model).lastTick.IsZero() {
+		t.Fatal("expected a normal tick to keep tracking")
+	}
+}
+
+func TestActiveDotPulsesWithTicks(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.ToggleStream(s.Streams[0].ID)
+	m := initialModel(s)
+	m.styles = newStyles(false)
+
+	var dots []string
+	for range 3 {
+		updated, _ := m.Update(tickMsg(time.Now()))
+		m = updated.(model)
+		dots = append(dots, m.activeDot())
+	}
+	if strings.Join(dots, "") != "○●○" {
+		t.Fatalf("expected the dot to alternate, got %v", dots)
+	}
+
+	m.animate = false
+	for range 2 {
+		updated, _ := m.Update(tickMsg(time.Now()))
+		m = updated.(model)
+		if m.activeDot() != "●" {
+			t.Fatal("expected a steady dot with animation off")
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"10h", 10 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"2.5", 150 * time.Minute, false},
+		{"0", 0, false},
+		{"-1", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTarget(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseTarget(%q) = %s, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestTargetRendering(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Client", 0)
+	s.AddStream("Plain", 1)
+	m := initialModel(s)
+	m = pressKeys(m, "g", "1", "0", "h", "enter")
+	view := m.View()
+	if !strings.Contains(view, "0h 00m / 10h 00m (0%)") {
+		t.Fatalf("expected target progress in view:\n%s", view)
+	}
+	for _, line := range strings.Split(view, "\n") {
+		if strings.Contains(line, "Plain") && strings.Contains(line, " / ") {
+			t.Fatalf("expected streams without a target to render without progress: %q", line)
+		}
+	}
+}
+
+func TestFilterNarrowsNavigation(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	s.AddStream("Client A", 1)
+	s.AddStream("Client B", 2)
+	m := initialModel(s)
+
+	m = pressKeys(m, "/", "c", "L", "i", "enter")
+	if m.filtering || m.filter != "cLi" {
+		t.Fatalf("expected filter kept after enter, got %q (filtering=%v)", m.filter, m.filtering)
+	}
+	if m.visibleCount() != 2 || s.Streams[m.cursor].Name != "Client A" {
+		t.Fatalf("expected cursor moved onto first match, got %q", s.Streams[m.cursor].Name)
+	}
+	m = pressKeys(m, "j", "j")
+	if s.Streams[m.cursor].Name != "Client A" {
+		t.Fatal("expected j to wrap within the filtered list")
+	}
+	m = pressKeys(m, "2")
+	if s.Streams[m.cursor].Name != "Client B" {
+		t.Fatal("expected number keys to count filtered rows")
+	}
+	if strings.Contains(m.View(), "Email") {
+		t.Fatal("expected non-matching stream hidden")
+	}
+
+	m = pressKeys(m, "esc")
+	if m.filter != "" || m.visibleCount() != 3 || s.Streams[m.cursor].Name != "Client B" {
+		t.Fatal("expected esc to clear the filter and keep the cursor")
+	}
+}
+
+func TestFilterEscWhileTypingClears(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	m := initialModel(s)
+	m = pressKeys(m, "/", "x", "esc")
+	if m.filtering || m.filter != "" || m.visibleCount() != 1 {
+		t.Fatal("expected esc to abandon the filter")
+	}
+}
+
+func TestPinMoveFollowsCursor(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	m := initialModel(s)
+
+	m = pressKeys(m, "3", "K")
+	if s.Streams[0].Name != "C" || m.cursor != 0 {
+		t.Fatalf("expected C pinned to the top with the cursor on it, got cursor %d", m.cursor)
+	}
+	m = pressKeys(m, "J")
+	if s.Streams[m.cursor].Name != "C" || s.Streams[m.cursor].Pinned {
+		t.Fatal("expected J to unpin C and the cursor to follow it")
+	}
+}
+
+func TestMarkAndMerge(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	s.AddStream("email (old)", 1)
+	m := initialModel(s)
+
+	m = pressKeys(m, "1", "m", "2", "M")
+	if len(s.Streams) != 1 || s.Streams[0].Name != "Email" || m.markedID != "" {
+		t.Fatal("expected email merged into the marked Email")
+	}
+	m = pressKeys(m, "u")
+	if len(s.Streams) != 2 {
+		t.Fatal("expected undo to bring the merged stream back")
+	}
+}
+
+func TestNextColorCycles(t *testing.T) {
+	c := ""
+	for range streamColors {
+		c = nextColor(c)
+	}
+	if c != "" {
+		t.Fatalf("expected the palette to wrap back to no color, got %q", c)
+	}
+	if nextColor("208") != "" {
+		t.Fatal("expected an unknown color to reset")
+	}
+}
+
+func TestNoColorStylesArePlain(t *testing.T) {
+	// Force a color terminal so the test means something when stdout isn't one.
+	prev := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	t.Cleanup(func() { lipgloss.SetColorProfile(prev) })
+	if !strings.Contains(newStyles(true).cursor.Render("> "), "\x1b") {
+		t.Fatal("expected color output to be styled")
+	}
+
+	st := newStyles(false)
+	for _, got := range []string{
+		st.cursor.Render("> "),
+		st.faint.Render("x"),
+		st.warn.Render("x"),
+		st.name("4").Render("x"),
+	} {
+		if strings.Contains(got, "\x1b") {
+			t.Fatalf("expected no escape codes, got %q", got)
+		}
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if !colorEnabled(false) || colorEnabled(true) {
+		t.Fatal("expected --no-color to decide when NO_COLOR is unset")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled(false) {
+		t.Fatal("expected NO_COLOR to disable color")
+	}
+}
+
+func TestUndoKeepsEventLog(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.ToggleStream(s.Streams[0].ID)
+	m := initialModel(s)
+	m = pressKeys(m, "d", "d", "y", "u")
+	if len(s.Streams) != 1 || !s.HasActive() {
+		t.Fatal("expected the delete undone")
+	}
+	var types []string
+	for _, e := range s.Events {
+		types = append(types, e.Type)
+	}
+	if got := strings.Join(types, ","); got != "add,start,stop,delete,edit" || s.Events[4].Detail != "undo" {
+		t.Fatalf("expected the undone delete to stay in the log, got %s", got)
+	}
+}
+
+func TestTodayMode(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	a := s.Streams[0].ID
+	midnight := s.DayStart(time.Now())
+	// Two hours yesterday and, if the day is old enough, one hour today.
+	y0, y1 := midnight.Add(-3*time.Hour), midnight.Add(-time.Hour)
+	s.Sessions = []store.Session{{Start: y0, End: &y1, Spans: []store.Span{{StreamID: a, Start: y0, End: &y1}}}}
+	want := time.Duration(0)
+	if t0 := time.Now().Add(-time.Hour); t0.After(midnight) {
+		t1 := t0.Add(time.Hour - time.Minute)
+		s.Sessions = append(s.Sessions, store.Session{Start: t0, End: &t1, Spans: []store.Span{{StreamID: a, Start: t0, End: &t1}}})
+		want = t1.Sub(t0).Truncate(time.Second)
+	}
+	m := initialModel(s)
+
+	m = pressKeys(m, "tab")
+	if got := m.elapsed(a); got != want {
+		t.Fatalf("expected only today's time (%s), got %s", want, got)
+	}
+	if got := m.wallClock(); got != want {
+		t.Fatalf("expected today's wall clock (%s), got %s", want, got)
+	}
+	if !strings.Contains(m.View(), "(today)") {
+		t.Fatal("expected the title to show today mode")
+	}
+
+	m = pressKeys(m, "tab")
+	if got := m.elapsed(a); got != 2*time.Hour+want {
+		t.Fatalf("expected lifetime time after toggling back, got %s", got)
+	}
+}
+
+func TestQuitSummary(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	s.AddStream("Idle", 1)
+
+	var out strings.Builder
+	writeQuitSummary(&out, s)
+	if out.Len() != 0 {
+		t.Fatalf("expected no summary with nothing running, got %q", out.String())
+	}
+
+	s.ToggleStream(s.Streams[0].ID)
+	writeQuitSummary(&out, s)
+	if !strings.Contains(out.String(), "Email") || strings.Contains(out.String(), "Idle") {
+		t.Fatalf("expected only the running stream listed, got %q", out.String())
+	}
+}
+
+func TestShiftQQuitsQuietly(t *testing.T) {
+	s := newTestStore(t)
+	m := initialModel(s)
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("Q")})
+	if !updated.(model).quietQuit || cmd == nil {
+		t.Fatal("expected Q to quit without the summary")
+	}
+}
+
+func TestAddDuplicateShowsInlineError(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	m := initialModel(s)
+	m = pressKeys(m, "o", "e", "m", "a", "i", "l", "enter")
+	if !m.adding || len(s.Streams) != 1 {
+		t.Fatal("expected the prompt to stay open without adding")
+	}
+	if !strings.Contains(m.View(), "already exists") {
+		t.Fatal("expected an inline duplicate message")
+	}
+	m = pressKeys(m, "2", "enter")
+	if m.adding || len(s.Streams) != 2 || m.startErr != "" {
+		t.Fatal("expected editing the name to clear the error and add")
+	}
+}
+
+func TestParsePastStart(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.Local)
+	got, err := parsePastStart("", 30*time.Minute, now)
+	if err != nil || !got.Equal(now.Add(-30*time.Minute)) {
+		t.Fatalf("expected empty input to end the block now, got %s %v", got, err)
+	}
+	got, err = parsePastStart("2024-03-04 14:15", time.Hour, now)
+	if err != nil || !got.Equal(time.Date(2024, 3, 4, 14, 15, 0, 0, time.Local)) {
+		t.Fatalf("expected a full date to parse, got %s %v", got, err)
+	}
+	if _, err := parsePastStart("2024-03-04 25:00", time.Hour, now); err == nil {
+		t.Fatal("expected an invalid date to fail")
+	}
+	if d, err := parseBlockDuration("45"); err != nil || d != 45*time.Minute {
+		t.Fatalf("expected plain numbers as minutes, got %s %v", d, err)
+	}
+}
+
+func TestLogPastSessionFlow(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	m := initialModel(s)
+	m = pressKeys(m, "L", "4", "5", "enter", "enter")
+	if m.pastSessionID != "" || len(s.Sessions) != 1 {
+		t.Fatal("expected a session logged ending now")
+	}
+	if got := s.Elapsed(s.Streams[0].ID); got != 45*time.Minute {
+		t.Fatalf("expected 45m on the stream, got %s", got)
+	}
+
+	// The same block again overlaps: the prompt stays open with the error.
+	m = pressKeys(m, "L", "4", "5", "enter", "enter")
+	if m.pastSessionID == "" || !strings.Contains(m.View(), "overlaps") {
+		t.Fatal("expected the overlap shown inline")
+	}
+	m = pressKeys(m, "esc")
+	if len(s.Sessions) != 1 || len(m.undo) != 1 {
+		t.Fatal("expected the refused block to leave no session or undo entry")
+	}
+}
+
+func TestNoteKey(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	m := initialModel(s)
+
+	m = pressKeys(m, "n", "fixed the flaky test", "enter")
+	if len(s.Streams[0].Notes) != 1 || s.Streams[0].Notes[0].Text != "fixed the flaky test" {
+		t.Fatalf("expected a note, got %+v", s.Streams[0].Notes)
+	}
+	if !strings.Contains(m.View(), "✎1") {
+		t.Fatal("expected the row to show the note indicator")
+	}
+
+	m = pressKeys(m, "n", "never mind", "esc")
+	if len(s.Streams[0].Notes) != 1 {
+		t.Fatal("expected esc to discard the note")
+	}
+	if m.textinput.CharLimit != nameCharLimit {
+		t.Fatalf("expected the name limit to be restored, got %d", m.textinput.CharLimit)
+	}
+}
+
+func TestDueNotificationsOncePerThreshold(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	start := time.Now().Add(-3*time.Hour - time.Minute)
+	s.ToggleStreamAt(s.Streams[0].ID, start)
+	m := initialModel(s)
+
+	if got := m.dueNotifications(time.Now()); got != nil {
+		t.Fatalf("expected no notifications when disabled, got %v", got)
+	}
+	m.notifyAfter = time.Hour
+	now := time.Now()
+	if got := m.dueNotifications(now); len(got) != 1 || !strings.Contains(got[0], "Email has been running for 3h") {
+		t.Fatalf("expected one catch-up notification, got %v", got)
+	}
+	if got := m.dueNotifications(now.Add(time.Minute)); len(got) != 0 {
+		t.Fatalf("expected the threshold to fire only once, got %v", got)
+	}
+	if got := m.dueNotifications(now.Add(time.Hour)); len(got) != 1 {
+		t.Fatalf("expected the next hour to notify again, got %v", got)
+	}
+
+	// A fresh run starts counting from zero.
+	s.ToggleStream(s.Streams[0].ID)
+	s.ToggleStream(s.Streams[0].ID)
+	if got := m.dueNotifications(time.Now()); len(got) != 0 {
+		t.Fatalf("expected a new run not to notify yet, got %v", got)
+	}
+	if len(m.notified) != 0 {
+		t.Fatal("expected the finished run to be forgotten")
+	}
+}
+
+func TestBar(t *testing.T) {
+	tests := []struct {
+		pct   float64
+		width int
+		want  string
+	}{
+		{0, 4, "    "},
+		{50, 4, "██  "},
+		{100, 4, "████"},
+		{56.25, 4, "██▎ "},
+		{150, 2, "██"},
+	}
+	for _, tt := range tests {
+		if got := bar(tt.pct, tt.width); got != tt.want {
+			t.Errorf("bar(%v, %d) = %q, want %q", tt.pct, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestBarsNeedAKnownWidth(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.ToggleStreamAt(s.Streams[0].ID, time.Now().Add(-time.Hour))
+	m := initialModel(s)
+	if strings.Contains(m.View(), "█") {
+		t.Fatal("expected no bars before the terminal width is known")
+	}
+	m.width = 120
+	if !strings.Contains(m.View(), strings.Repeat("█", barWidth(120))) {
+		t.Fatal("expected a full bar for the only stream")
+	}
+	if barWidth(60) != 0 {
+		t.Fatal("expected no bars on a narrow terminal")
+	}
+}
+
+func TestHistoryViewKeepsCursor(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	b := s.Streams[1].ID
+	s.ToggleStreamAt(b, time.Now().Add(-time.Hour))
+	s.ToggleStream(b)
+	m := initialModel(s)
+	m.follow(b)
+	cursor := m.cursor
+
+	m = pressKeys(m, "h")
+	view := m.View()
+	if !strings.Contains(view, "History: B") || !strings.Contains(view, "(1h 00m") {
+		t.Fatalf("expected B's history with its run:\n%s", view)
+	}
+	m = pressKeys(m, "esc")
+	if m.historyID != "" || m.cursor != cursor {
+		t.Fatal("expected esc to return to the list with the cursor where it was")
+	}
+}
+
+func TestStalePromptStopsAtLastSave(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.ToggleStreamAt(s.Streams[0].ID, time.Now().Add(-20*time.Hour))
+	saved := time.Now().Add(-18 * time.Hour)
+	s.LastSavedAt = &saved
+
+	m := initialModel(s)
+	if !strings.Contains(m.View(), "Stop them as of then?") {
+		t.Fatal("expected the stale tracking prompt")
+	}
+	m = pressKeys(m, "y")
+	if s.HasActive() || m.staleSince != nil {
+		t.Fatal("expected y to stop tracking and close the prompt")
+	}
+	if got := s.TotalWallClock(); got != 2*time.Hour {
+		t.Fatalf("expected tracking to end at the last save, got %s", got)
+	}
+
+	// Any other key keeps the time.
+	s2 := newTestStore(t)
+	s2.AddStream("A", 0)
+	s2.ToggleStreamAt(s2.Streams[0].ID, time.Now().Add(-20*time.Hour))
+	s2.LastSavedAt = &saved
+	m = pressKeys(initialModel(s2), "n")
+	if !s2.HasActive() {
+		t.Fatal("expected n to leave the streams running")
+	}
+}
+
+func TestSortKeyKeepsCursorOnStream(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("b", 0)
+	s.AddStream("c", 1)
+	s.AddStream("a", 2)
+	m := initialModel(s)
+	m.follow(s.Streams[1].ID) // "c"
+
+	m = pressKeys(m, "=")
+	if s.SortMode != store.SortAlpha || s.Streams[m.cursor].Name != "c" {
+		t.Fatalf("expected alphabetical order with the cursor still on c, got %s at %d", s.Streams[m.cursor].Name, m.cursor)
+	}
+	if !strings.Contains(m.View(), "(by name)") {
+		t.Fatal("expected the title to show the sort mode")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0h 00m 00s"},
+		{30 * time.Second, "0h 00m 30s"},
+		{5 * time.Minute, "0h 05m 00s"},
+		{time.Hour + 2*time.Minute + 15*time.Second, "1h 02m 15s"},
+		{25 * time.Hour, "25h 00m 00s"},
+	}
+	for _, tt := range tests {
+		got := formatDuration(tt.d)
+		if got != tt.want {
+			t.Errorf("formatDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestSplitFlow(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Work", 0)
+	if err := s.AddPastSession(s.Streams[0].ID, time.Now().Add(-3*time.Hour), 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(s)
+	m = pressKeys(m, "|", "W", "o", "r", "k", "enter")
+	if m.splitName != "" || !strings.Contains(m.View(), "already exists") {
+		t.Fatal("expected a taken name refused at the name prompt")
+	}
+	m = pressKeys(m, "backspace", "backspace", "backspace", "backspace", "R", "enter", "3", "h", "enter")
+	if m.splittingID == "" || !strings.Contains(m.View(), "finished time") {
+		t.Fatal("expected too large an amount shown inline")
+	}
+	m = pressKeys(m, "backspace", "backspace", "4", "5", "enter")
+	if m.splittingID != "" || len(s.Streams) != 2 || len(m.undo) != 1 {
+		t.Fatal("expected the split done with one undo entry")
+	}
+	if got := s.Elapsed(m.cursorID()); got != 75*time.Minute {
+		t.Fatalf("expected the cursor to stay on the source with 1h15m left, got %s", got)
+	}
+}
+
+func TestFooterShowsLastActive(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	m := initialModel(s)
+	if strings.Contains(m.View(), "Last active") {
+		t.Fatal("expected no last-active line without history")
+	}
+	if err := s.AddPastSession(s.Streams[0].ID, time.Now().Add(-3*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(m.View(), "Last active: 2h ago") {
+		t.Fatal("expected the footer to say when tracking last stopped")
+	}
+	m = pressKeys(m, "enter")
+	if strings.Contains(m.View(), "Last active") {
+		t.Fatal("expected the line hidden while a stream runs")
+	}
+}
+
+func TestFooterShowsCurrentSession(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	m := initialModel(s)
+	if strings.Contains(m.View(), "This session") {
+		t.Fatal("expected no session line while idle")
+	}
+	s.ToggleStreamAt(s.Streams[0].ID, time.Now().Add(-45*time.Minute))
+	if !strings.Contains(m.View(), "This session: 0h 45m") {
+		t.Fatal("expected the footer to show the open session's length")
+	}
+}
+
+func TestPomodoroStopsStreamWhenDone(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	m := initialModel(s)
+	m = pressKeys(m, "P")
+	id := m.pomodoroID
+	if id == "" || !s.StreamByID(id).Active || !strings.Contains(m.View(), "25:00 left") {
+		t.Fatal("expected P to start the stream with a 25-minute countdown")
+	}
+
+	updated, _ := m.Update(tickMsg(time.Now()))
+	m = updated.(model)
+	if !s.StreamByID(id).Active {
+		t.Fatal("expected the stream to keep running before the interval ends")
+	}
+	updated, cmd := m.Update(tickMsg(m.pomodoroEnd))
+	m = updated.(model)
+	if s.HasActive() || m.pomodoroID != "" || cmd == nil {
+		t.Fatal("expected the stream stopped, with a notification, once the interval ended")
+	}
+	if !strings.Contains(m.View(), "Take a 5-minute break") || len(s.Sessions) != 1 {
+		t.Fatal("expected a break suggested and the focus time recorded as a session")
+	}
+}
+
+func TestPomodoroEndsEarlyOrWithItsStream(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	m := initialModel(s)
+	m = pressKeys(m, "P", "P")
+	if s.HasActive() || m.pomodoroID != "" {
+		t.Fatal("expected a second P to end the pomodoro and stop the stream")
+	}
+
+	m = pressKeys(m, "P", "enter")
+	updated, _ := m.Update(tickMsg(time.Now()))
+	m = updated.(model)
+	if m.pomodoroID != "" || strings.Contains(m.View(), "Pomodoro") {
+		t.Fatal("expected stopping the stream by hand to drop the pomodoro")
+	}
+}
+
+func TestPercentBasisToggle(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+	t1, t2 := t0.Add(time.Hour), t0.Add(2*time.Hour)
+	s.Sessions = []store.Session{{
+		Start: t0,
+		End:   &t2,
+		Spans: []store.Span{
+			{StreamID: a, Start: t0, End: &t2},
+			{StreamID: b, Start: t0, End: &t1},
+		},
+	}}
+	m := initialModel(s)
+	m.styles = newStyles(false)
+	view := m.View()
+	if !strings.Contains(view, "2h 00m 00s  100%") || !strings.Contains(view, "1h 00m 00s   50%") {
+		t.Fatalf("expected percentages of the wall clock by default:\n%s", view)
+	}
+	m = pressKeys(m, "%")
+	view = m.View()
+	if !strings.Contains(view, "2h 00m 00s   67%") || !strings.Contains(view, "1h 00m 00s   33%") || !strings.Contains(view, "% of stream time") {
+		t.Fatalf("expected percentages of the streams' summed time:\n%s", view)
+	}
+}
+
+func TestEmptyStreamsAreTaggedAndDeletedWithoutAsking(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Used", 0)
+	s.AddStream("Fresh", 1)
+	if err := s.AddPastSession(s.Streams[0].ID, time.Now().Add(-2*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(s)
+	view := m.View()
+	if !strings.Contains(view, "Fresh") || strings.Count(view, "(empty)") != 1 {
+		t.Fatalf("expected only the stream without time tagged:\n%s", view)
+	}
+
+	m.follow(s.Streams[1].ID)
+	m = pressKeys(m, "d", "d")
+	if m.confirmDel || len(s.Streams) != 1 || len(s.Trash) != 1 {
+		t.Fatal("expected dd to delete an empty stream without asking")
+	}
+	m = pressKeys(m, "d", "d")
+	if !m.confirmDel || len(s.Streams) != 1 {
+		t.Fatal("expected dd to still ask before deleting recorded time")
+	}
+	m = pressKeys(m, "n", "D")
+	if len(s.Streams) != 0 || len(s.Trash) != 2 {
+		t.Fatal("expected D to delete immediately whatever was recorded")
+	}
+	m = pressKeys(m, "u")
+	if len(s.Streams) != 1 {
+		t.Fatal("expected D to be undoable")
+	}
+}
+
+func TestFormatAgo(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		10 * time.Second:          "just now",
+		5 * time.Minute:           "5m ago",
+		2*time.Hour + time.Minute: "2h ago",
+		50 * time.Hour:            "2d ago",
+	} {
+		if got := formatAgo(d); got != want {
+			t.Errorf("formatAgo(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestResetKeyAsksFirst(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+	if err := s.AddPastSession(id, time.Now().Add(-2*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(s)
+
+	m = pressKeys(m, "R")
+	if !strings.Contains(m.View(), `Reset "A" to zero?`) {
+		t.Fatal("expected a confirmation prompt")
+	}
+	m = pressKeys(m, "n")
+	if s.Elapsed(id) != time.Hour {
+		t.Fatal("expected n to leave the stream alone")
+	}
+	m = pressKeys(m, "R", "y")
+	if s.Elapsed(id) != 0 {
+		t.Fatalf("expected y to reset, got %v", s.Elapsed(id))
+	}
+	pressKeys(m, "u")
+	if s.Elapsed(id) != time.Hour {
+		t.Fatal("expected u to undo the reset")
+	}
+}
+
+func TestExcludedStreamLeftOutOfTotal(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Work", 0)
+	s.AddStream("Break", 1)
+	now := time.Now()
+	if err := s.AddPastSession(s.Streams[0].ID, now.Add(-3*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddPastSession(s.Streams[1].ID, now.Add(-time.Hour), 30*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(s)
+	if strings.Contains(m.View(), "Total:") {
+		t.Fatal("expected no total line while nothing is excluded")
+	}
+
+	m.follow(s.Streams[1].ID)
+	m = pressKeys(m, "x")
+	view := m.View()
+	if !strings.Contains(view, "Total: 1h 00m 00s (excluding Break)") {
+		t.Fatalf("expected the total to leave Break out, got:\n%s", view)
+	}
+	if !strings.Contains(view, "Wall clock: 1h 30m 00s") {
+		t.Fatal("expected the wall clock unchanged")
+	}
+	if !strings.Contains(view, "(not in total)") {
+		t.Fatal("expected the excluded row marked")
+	}
+}
+
+func TestTinyTerminalShowsMessageAndIgnoresKeys(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	m := initialModel(s)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 30, Height: 6})
+	m = updated.(model)
+
+	if view := m.View(); !strings.Contains(view, "Terminal too small") || strings.Contains(view, "Wall clock") {
+		t.Fatalf("expected only the too-small message, got:\n%s", view)
+	}
+	m = pressKeys(m, "enter")
+	if s.HasActive() {
+		t.Fatal("expected keys other than quit to be ignored")
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); cmd == nil {
+		t.Fatal("expected q to still quit")
+	}
+
+	updated, _ = m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = updated.(model)
+	if strings.Contains(m.View(), "Terminal too small") {
+		t.Fatal("expected the normal view once the window grows")
+	}
+}
+
+func TestParseAnchor(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.Local)
+	if at, err := parseAnchor(s, "now", now); err != nil || !at.Equal(now) {
+		t.Fatalf("now: got %v, %v", at, err)
+	}
+	if at, err := parseAnchor(s, "off", now); err != nil || at != nil {
+		t.Fatalf("off: got %v, %v", at, err)
+	}
+	want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	if at, err := parseAnchor(s, "2024-03-01", now); err != nil || !at.Equal(want) {
+		t.Fatalf("date: got %v, %v", at, err)
+	}
+	if _, err := parseAnchor(s, "yesterday", now); err == nil {
+		t.Fatal("expected an error for an unknown value")
+	}
+}
+
+func TestAnchorKeyToggles(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	m := initialModel(s)
+
+	m = pressKeys(m, "N")
+	if s.Anchor == nil || !strings.Contains(m.View(), "(since ") {
+		t.Fatal("expected N to anchor the totals at now")
+	}
+	pressKeys(m, "N")
+	if s.Anchor != nil {
+		t.Fatal("expected a second N to clear the anchor")
+	}
+}
+
+func TestJumpToBusiest(t *testing.T) {
+	s := newTestStore(t)
+	m := initialModel(s)
+	m = pressKeys(m, ".")
+	if m.cursor != 0 {
+		t.Fatal("expected . to do nothing on an empty list")
+	}
+
+	for i, name := range []string{"A", "B", "C"} {
+		s.AddStream(name, i)
+	}
+	now := time.Now()
+	if err := s.AddPastSession(s.Streams[1].ID, now.Add(-3*time.Hour), 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddPastSession(s.Streams[2].ID, now.Add(-30*time.Minute), 10*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	m = pressKeys(m, ".")
+	if s.Streams[m.cursor].Name != "B" {
+		t.Fatalf("expected the stream with the most time, got %s", s.Streams[m.cursor].Name)
+	}
+
+	s.ToggleStream(s.Streams[2].ID)
+	m.cursor = 0
+	m = pressKeys(m, ".")
+	if s.Streams[m.cursor].Name != "C" {
+		t.Fatalf("expected the running stream, got %s", s.Streams[m.cursor].Name)
+	}
+}
+
+func TestReadOnlyRunLeavesCorruptFileAlone(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urd.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []runOptions{{report: "json"}, {status: true}, {jsonStream: "A"}, {exportCSV: true}} {
+		if err := run(path, &Config{}, opts); err == nil {
+			t.Fatalf("expected %+v to fail on a corrupt file", opts)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if len(entries) != 1 || string(data) != "{" {
+		t.Fatal("expected the unlocked runs to leave the file untouched")
+	}
+}
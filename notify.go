@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// notify shows a desktop notification with whatever the platform ships:
+// notify-send on Linux and the BSDs, osascript on macOS, and a PowerShell
+// toast on Windows. It shells out rather than pulling in a notification
+// library because this is a best-effort nudge; if the tool is missing the
+// error is simply dropped by the caller.
+func notify(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := "display notification " + appleScriptString(body) + " with title " + appleScriptString(title)
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		// The text goes through the environment so nothing in a stream
+		// name needs escaping for PowerShell.
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToast)
+		cmd.Env = append(os.Environ(), "URD_TITLE="+title, "URD_BODY="+body)
+	default:
+		cmd = exec.Command("notify-send", "--app-name=urd", title, body)
+	}
+	return cmd.Run()
+}
+
+// notifyCmd runs notify off the UI goroutine, since the helper process can
+// take a moment to start.
+func notifyCmd(title, body string) tea.Cmd {
+	return func() tea.Msg {
+		notify(title, body)
+		return nil
+	}
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// windowsToast shows $env:URD_TITLE and $env:URD_BODY as a toast using the
+// WinRT API that every Windows 10+ PowerShell can load.
+const windowsToast = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:URD_TITLE)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:URD_BODY)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('urd').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fjordengineering/urd/store"
+)
+
+// Report is the machine-readable summary printed by `--report json`. It's
+// computed from the same Store methods the TUI uses (Elapsed,
+// TotalWallClock, percentOf), so numbers in scripts match what's on screen.
+// RoundingMinutes is the billing increment applied to each stream's
+// BilledSeconds, omitted when there is none.
+type Report struct {
+	WallClockSeconds int64          `json:"wall_clock_seconds"`
+	SessionCount     int            `json:"session_count"`
+	RoundingMinutes  float64        `json:"rounding_minutes,omitempty"`
+	FirstActivity    *time.Time     `json:"first_activity,omitempty"`
+	LastActivity     *time.Time     `json:"last_activity,omitempty"`
+	Streams          []StreamReport `json:"streams"`
+}
+
+// StreamReport is one stream's line in a Report. Percent is relative to
+// wall clock, as in the TUI. BilledSeconds is ElapsedSeconds rounded up to
+// the report's increment, or equal to it without one; Percent always uses
+// the exact time.
+type StreamReport struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	ElapsedSeconds int64   `json:"elapsed_seconds"`
+	BilledSeconds  int64   `json:"billed_seconds"`
+	Percent        float64 `json:"percent"`
+	Active         bool    `json:"active"`
+}
+
+// roundUp rounds d up to the next multiple of step, for billing in fixed
+// increments: with a 15m step, 1m bills as 15m and 16m as 30m. No time
+// stays zero, and a step of zero or less leaves d exact.
+func roundUp(d, step time.Duration) time.Duration {
+	if step <= 0 || d <= 0 {
+		return d
+	}
+	return (d + step - 1) / step * step
+}
+
+// ReportRange bounds a report to [Since, Until). A zero field leaves that
+// side open, so the zero ReportRange covers everything.
+type ReportRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+// clip narrows [start, end) to the range and reports whether anything is
+// left. Sessions straddling a boundary keep their inside part rather than
+// being dropped, so adjacent ranges add up exactly.
+func (rng ReportRange) clip(start, end time.Time) (time.Time, time.Time, bool) {
+	if !rng.Since.IsZero() && start.Before(rng.Since) {
+		start = rng.Since
+	}
+	if !rng.Until.IsZero() && end.After(rng.Until) {
+		end = rng.Until
+	}
+	return start, end, end.After(start)
+}
+
+// parseReportBound parses a --since or --until value: an RFC3339 instant,
+// or a YYYY-MM-DD date. Dates follow `urd total`: both are inclusive, so a
+// --since date starts at the beginning of its day and an --until date runs
+// to the end of it, with days starting at the store's DayStartHour.
+func parseReportBound(s *store.Store, value string, until bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := parseDay(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC3339", value)
+	}
+	if until {
+		return s.DayOn(day, 1), nil
+	}
+	return s.DayOn(day, 0), nil
+}
+
+// BuildReport summarizes the store as of now, counting only time inside
+// rng: wall clock and every stream's spans are clipped to it, and sessions
+// that don't overlap it at all aren't counted. Without a range the report
+// is the TUI's lifetime view instead: Elapsed and TotalWallClock, so the
+// anchor and each stream's reset apply just as they do on screen, and
+// Percent divides by the same wall clock as the TUI's default percentage
+// column. LastActivity is now while a session is open, since tracking is
+// still happening. round is the billing increment for BilledSeconds (0 for
+// none).
+func BuildReport(s *store.Store, now time.Time, rng ReportRange, round time.Duration) Report {
+	wallClock := s.WallClockBetween(rng.Since, rng.Until)
+	elapsed := func(id string) time.Duration { return s.StreamTimeBetween(id, rng.Since, rng.Until) }
+	if rng == (ReportRange{}) {
+		wallClock = s.TotalWallClock()
+		elapsed = s.Elapsed
+		if s.Anchor != nil {
+			rng.Since = *s.Anchor
+		}
+	}
+	r := Report{
+		WallClockSeconds: int64(wallClock.Seconds()),
+		RoundingMinutes:  max(round, 0).Minutes(),
+		Streams:          []StreamReport{},
+	}
+	for _, sess := range s.Sessions {
+		end := now
+		if sess.End != nil {
+			end = *sess.End
+		}
+		start, end, ok := rng.clip(sess.Start, end)
+		if !ok {
+			continue
+		}
+		r.SessionCount++
+		if r.FirstActivity == nil || start.Before(*r.FirstActivity) {
+			r.FirstActivity = &start
+		}
+		if r.LastActivity == nil || end.After(*r.LastActivity) {
+			r.LastActivity = &end
+		}
+	}
+	for _, st := range s.Streams {
+		d := elapsed(st.ID)
+		r.Streams = append(r.Streams, StreamReport{
+			ID:             st.ID,
+			Name:           st.Name,
+			ElapsedSeconds: int64(d.Seconds()),
+			BilledSeconds:  int64(roundUp(d, round).Seconds()),
+			Percent:        percentOf(d, wallClock),
+			Active:         st.Active,
+		})
+	}
+	return r
+}
+
+// RollupPeriod is one row of a `--report week` or `--report month` table:
+// a local-time range [Start, End) with its wall clock and the streams that
+// had time in it.
+type RollupPeriod struct {
+	Label     string
+	Start     time.Time
+	End       time.Time
+	WallClock time.Duration
+	Streams   []RollupStream
+}
+
+// RollupStream is one stream's time within a RollupPeriod.
+type RollupStream struct {
+	Name    string
+	Elapsed time.Duration
+}
+
+// BuildRollup aggregates the store into periods ending at now. "week" is
+// the last 7 days including today, one row per day; "month" is the current
+// calendar month up to now, one row per Monday-based week clipped to the
+// month. Boundaries are day starts from Store.DayStart (local midnight, or
+// DayStartHour), built with time.Date so DST days are 23 or 25 hours long
+// rather than shifting every later row. Periods with no activity are kept,
+// so the table has no gaps.
+// A range with an Until in the past anchors the table there instead of at
+// now, so `--report month --until 2024-02-29` is February. Periods are then
+// clipped to the range, and any left with no overlap are dropped.
+func BuildRollup(s *store.Store, kind string, now time.Time, rng ReportRange) ([]RollupPeriod, error) {
+	if !rng.Until.IsZero() && rng.Until.Before(now) {
+		now = rng.Until.Add(-time.Nanosecond)
+	}
+	today := s.DayStart(now)
+	var periods []RollupPeriod
+	switch kind {
+	case "week":
+		for i := 6; i >= 0; i-- {
+			start := today.AddDate(0, 0, -i)
+			end := s.DayOn(start, 1)
+			periods = append(periods, RollupPeriod{Label: start.Format("Mon 2006-01-02"), Start: start, End: end})
+		}
+	case "month":
+		first := s.DayOn(today, 1-today.Day())
+		next := first.AddDate(0, 1, 0)
+		for start := first; !start.After(today); {
+			// Days until the following Monday; Sunday is 0 in time.Weekday.
+			days := (8 - int(start.Weekday())) % 7
+			if days == 0 {
+				days = 7
+			}
+			end := s.DayOn(start, days)
+			if end.After(next) {
+				end = next
+			}
+			last := end.AddDate(0, 0, -1)
+			label := start.Format("Jan 02") + "–" + last.Format("02")
+			periods = append(periods, RollupPeriod{Label: label, Start: start, End: end})
+			start = end
+		}
+	default:
+		return nil, fmt.Errorf("unknown report format %q", kind)
+	}
+	clipped := periods[:0]
+	for _, p := range periods {
+		var ok bool
+		if p.Start, p.End, ok = rng.clip(p.Start, p.End); ok {
+			clipped = append(clipped, p)
+		}
+	}
+	periods = clipped
+	for i := range periods {
+		p := &periods[i]
+		p.WallClock = s.WallClockBetween(p.Start, p.End)
+		for _, st := range s.Streams {
+			if d := s.StreamTimeBetween(st.ID, p.Start, p.End); d > 0 {
+				p.Streams = append(p.Streams, RollupStream{Name: st.Name, Elapsed: d})
+			}
+		}
+	}
+	return periods, nil
+}
+
+// writeRollup prints periods as an aligned text table: each period's wall
+// clock, its streams indented beneath, and the overall total last. With a
+// billing increment, each stream line is rounded up to it (per period, as
+// a timesheet would bill each day or week) and a Billed line totals them.
+// Wall-clock lines stay exact.
+func writeRollup(w io.Writer, periods []RollupPeriod, round time.Duration) {
+	var total, billed time.Duration
+	for _, p := range periods {
+		fmt.Fprintf(w, "%-24s  %11s\n", p.Label, formatDuration(p.WallClock))
+		for _, st := range p.Streams {
+			d := roundUp(st.Elapsed, round)
+			fmt.Fprintf(w, "  %-22s  %11s\n", st.Name, formatDuration(d))
+			billed += d
+		}
+		total += p.WallClock
+	}
+	fmt.Fprintf(w, "%-24s  %11s\n", "Total", formatDuration(total))
+	if round > 0 {
+		fmt.Fprintf(w, "%-24s  %11s\n", fmt.Sprintf("Billed (%s steps)", formatHoursMinutes(round)), formatDuration(billed))
+	}
+}
+
+// writeReport renders the report in the requested format.
+func writeReport(w io.Writer, s *store.Store, format string, rng ReportRange, round time.Duration) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(BuildReport(s, time.Now(), rng, round))
+	case "week", "month":
+		periods, err := BuildRollup(s, format, time.Now(), rng)
+		if err != nil {
+			return err
+		}
+		writeRollup(w, periods, round)
+		return nil
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+// statusLine is the compact one-liner printed by --status for shell and
+// tmux prompts: the active stream with the most elapsed time, e.g.
+// "● Email 1h 02m", with "+N" when others are running too, or "idle".
+// Elapsed is the stream's lifetime total, as in the TUI.
+func statusLine(s *store.Store) string {
+	active := s.ActiveStreams()
+	if len(active) == 0 {
+		return "idle"
+	}
+	var top *store.Stream
+	var topElapsed time.Duration
+	for _, st := range active {
+		if elapsed := s.Elapsed(st.ID); top == nil || elapsed > topElapsed {
+			top, topElapsed = st, elapsed
+		}
+	}
+	line := fmt.Sprintf("● %s %s", top.Name, formatHoursMinutes(topElapsed))
+	if len(active) > 1 {
+		line += fmt.Sprintf(" +%d", len(active)-1)
+	}
+	return line
+}
+
+// StreamStatus is what --json-stream prints for one stream: its lifetime
+// time as shown in the TUI (ElapsedSeconds, including a run in progress)
+// and, while it's running, how long the current run has lasted.
+type StreamStatus struct {
+	ID                string     `json:"id"`
+	Name              string     `json:"name"`
+	ElapsedSeconds    int64      `json:"elapsed_seconds"`
+	Active            bool       `json:"active"`
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	CurrentRunSeconds int64      `json:"current_run_seconds"`
+}
+
+// writeStreamJSON prints the StreamStatus of the stream matching query, a
+// name or ID (see findStreamID), for scripts that only care about one
+// stream and would rather not pick it out of the full report.
+func writeStreamJSON(w io.Writer, s *store.Store, query string) error {
+	id, err := findStreamID(s, query)
+	if err != nil {
+		return err
+	}
+	st := s.StreamByID(id)
+	status := StreamStatus{
+		ID:             st.ID,
+		Name:           st.Name,
+		ElapsedSeconds: int64(s.Elapsed(id).Seconds()),
+		Active:         st.Active,
+	}
+	if st.Active && st.StartedAt != nil {
+		status.StartedAt = st.StartedAt
+		status.CurrentRunSeconds = int64(max(time.Since(*st.StartedAt), 0).Seconds())
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(status)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fjordengineering/urd/store"
+)
+
+func TestBuildReport(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+	mid := start.Add(time.Hour)
+	end := start.Add(2 * time.Hour)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+	s.Sessions = []store.Session{{
+		Start: start,
+		End:   &end,
+		Spans: []store.Span{
+			{StreamID: a, Start: start, End: &end},
+			{StreamID: b, Start: mid, End: &end},
+		},
+	}}
+
+	r := BuildReport(s, end.Add(time.Hour), ReportRange{}, 0)
+	if r.WallClockSeconds != 7200 || r.SessionCount != 1 {
+		t.Fatalf("unexpected totals: %+v", r)
+	}
+	if !r.FirstActivity.Equal(start) || !r.LastActivity.Equal(end) {
+		t.Fatal("expected first/last activity from the session bounds")
+	}
+	if r.Streams[0].ElapsedSeconds != 7200 || r.Streams[0].Percent != 100 {
+		t.Fatalf("unexpected A: %+v", r.Streams[0])
+	}
+	if r.Streams[1].ElapsedSeconds != 3600 || r.Streams[1].Percent != 50 {
+		t.Fatalf("unexpected B: %+v", r.Streams[1])
+	}
+}
+
+func TestBuildReportWithoutRangeMatchesTUI(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+	start := time.Now().Add(-4 * time.Hour)
+	mid, end := start.Add(2*time.Hour), start.Add(3*time.Hour)
+	s.Sessions = []store.Session{{Start: start, End: &end, Spans: []store.Span{
+		{StreamID: a, Start: start, End: &end},
+		{StreamID: b, Start: start, End: &end},
+	}}}
+	s.ResetStream(b)
+	s.SetAnchor(&mid)
+
+	r := BuildReport(s, time.Now(), ReportRange{}, 0)
+	if r.WallClockSeconds != 3600 || r.Streams[0].ElapsedSeconds != 3600 || r.Streams[0].Percent != 100 {
+		t.Fatalf("expected totals counted from the anchor, got %+v", r)
+	}
+	if r.Streams[1].ElapsedSeconds != 0 || r.Streams[1].Percent != 0 {
+		t.Fatalf("expected the reset stream at zero, got %+v", r.Streams[1])
+	}
+	if !r.FirstActivity.Equal(mid) {
+		t.Fatal("expected activity before the anchor to be left out")
+	}
+
+	r = BuildReport(s, time.Now(), ReportRange{Since: start.Add(-time.Hour)}, 0)
+	if r.WallClockSeconds != 3*3600 || r.Streams[1].ElapsedSeconds != 3*3600 {
+		t.Fatalf("expected an explicit range to see everything, got %+v", r)
+	}
+}
+
+func TestBuildReportRangeClipsSessions(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	a := s.Streams[0].ID
+	// 23:00 on the 29th to 01:00 on the 1st: an hour in each month.
+	start := time.Date(2024, 2, 29, 23, 0, 0, 0, time.Local)
+	end := start.Add(2 * time.Hour)
+	s.Sessions = []store.Session{{Start: start, End: &end, Spans: []store.Span{{StreamID: a, Start: start, End: &end}}}}
+
+	since, err := parseReportBound(s, "2024-03-01", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := BuildReport(s, end, ReportRange{Since: since}, 0)
+	if r.WallClockSeconds != 3600 || r.Streams[0].ElapsedSeconds != 3600 || r.SessionCount != 1 {
+		t.Fatalf("expected the session clipped to its March hour, got %+v", r)
+	}
+	if !r.FirstActivity.Equal(since) {
+		t.Fatalf("expected first activity clipped to the range, got %s", r.FirstActivity)
+	}
+
+	until, err := parseReportBound(s, "2024-02-28", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := BuildReport(s, end, ReportRange{Until: until}, 0); r.SessionCount != 0 || r.WallClockSeconds != 0 {
+		t.Fatalf("expected nothing before the session, got %+v", r)
+	}
+	if _, err := parseReportBound(s, "last tuesday", false); err == nil {
+		t.Fatal("expected an error for an unparseable date")
+	}
+	if got, _ := parseReportBound(s, "2024-03-01T12:00:00Z", true); !got.Equal(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected an RFC3339 bound to be used as is, got %s", got)
+	}
+}
+
+func TestBuildRollupAnchorsAtUntil(t *testing.T) {
+	s := newTestStore(t)
+	until, _ := parseReportBound(s, "2024-02-29", true)
+	since, _ := parseReportBound(s, "2024-02-10", false)
+	now := time.Date(2024, 3, 12, 12, 0, 0, 0, time.Local)
+	periods, err := BuildRollup(s, "month", now, ReportRange{Since: since, Until: until})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, p := range periods {
+		labels = append(labels, p.Label)
+	}
+	// February 2024 starts on a Thursday; the first two weeks fall before
+	// --since, and the week of the 5th is clipped to start on the 10th.
+	if got := strings.Join(labels, ","); got != "Feb 05–11,Feb 12–18,Feb 19–25,Feb 26–29" {
+		t.Fatalf("unexpected periods %s", got)
+	}
+	if !periods[0].Start.Equal(since) {
+		t.Fatalf("expected the first period clipped to --since, got %s", periods[0].Start)
+	}
+}
+
+func TestRoundUp(t *testing.T) {
+	step := 15 * time.Minute
+	tests := []struct{ in, want time.Duration }{
+		{0, 0},
+		{time.Second, 15 * time.Minute},
+		{15 * time.Minute, 15 * time.Minute},
+		{16 * time.Minute, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := roundUp(tt.in, step); got != tt.want {
+			t.Errorf("roundUp(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	if got := roundUp(16*time.Minute, 0); got != 16*time.Minute {
+		t.Fatalf("expected no rounding without a step, got %s", got)
+	}
+}
+
+func TestReportRounding(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	a := s.Streams[0].ID
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+	end := start.Add(61 * time.Minute)
+	s.Sessions = []store.Session{{Start: start, End: &end, Spans: []store.Span{{StreamID: a, Start: start, End: &end}}}}
+
+	r := BuildReport(s, end, ReportRange{}, 15*time.Minute)
+	if r.Streams[0].ElapsedSeconds != 61*60 || r.Streams[0].BilledSeconds != 75*60 || r.RoundingMinutes != 15 {
+		t.Fatalf("expected exact and billed time side by side, got %+v", r)
+	}
+
+	periods, err := BuildRollup(s, "week", end, ReportRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	writeRollup(&out, periods, 15*time.Minute)
+	if !strings.Contains(out.String(), "  A                        1h 15m 00s") {
+		t.Fatalf("expected the stream line rounded up:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Total                      1h 01m 00s") || !strings.Contains(out.String(), "Billed (0h 15m steps)      1h 15m 00s") {
+		t.Fatalf("expected an exact total and a billed total:\n%s", out.String())
+	}
+}
+
+func TestWriteReportJSON(t *testing.T) {
+	s := newTestStore(t)
+	var out bytes.Buffer
+	if err := writeReport(&out, s, "json", ReportRange{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	var r Report
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatalf("expected valid JSON: %v", err)
+	}
+	if r.Streams == nil || r.FirstActivity != nil {
+		t.Fatal("expected an empty stream list and no activity for an empty store")
+	}
+	if err := writeReport(&out, s, "xml", ReportRange{}, 0); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestBuildRollupWeek(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	a := s.Streams[0].ID
+	// 23:00–01:00 across midnight into the last day of the week.
+	start := time.Date(2024, 3, 9, 23, 0, 0, 0, time.Local)
+	end := start.Add(2 * time.Hour)
+	s.Sessions = []store.Session{{
+		Start: start,
+		End:   &end,
+		Spans: []store.Span{{StreamID: a, Start: start, End: &end}},
+	}}
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	periods, err := BuildRollup(s, "week", now, ReportRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 7 {
+		t.Fatalf("expected 7 days, got %d", len(periods))
+	}
+	if periods[0].Label != "Mon 2024-03-04" || periods[6].Label != "Sun 2024-03-10" {
+		t.Fatalf("unexpected range %s .. %s", periods[0].Label, periods[6].Label)
+	}
+	if periods[5].WallClock != time.Hour || periods[6].WallClock != time.Hour {
+		t.Fatalf("expected the session split at midnight, got %s / %s", periods[5].WallClock, periods[6].WallClock)
+	}
+	if len(periods[6].Streams) != 1 || periods[6].Streams[0].Elapsed != time.Hour {
+		t.Fatalf("unexpected stream breakdown: %+v", periods[6].Streams)
+	}
+	if periods[0].WallClock != 0 || len(periods[0].Streams) != 0 {
+		t.Fatal("expected empty days to be kept with zero")
+	}
+}
+
+func TestBuildRollupMonthWeeks(t *testing.T) {
+	s := newTestStore(t)
+	// 2024-03-01 is a Friday.
+	now := time.Date(2024, 3, 12, 12, 0, 0, 0, time.Local)
+	periods, err := BuildRollup(s, "month", now, ReportRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, p := range periods {
+		labels = append(labels, p.Label)
+	}
+	want := []string{"Mar 01–03", "Mar 04–10", "Mar 11–17"}
+	if len(labels) != len(want) {
+		t.Fatalf("got %v, want %v", labels, want)
+	}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Fatalf("got %v, want %v", labels, want)
+		}
+	}
+}
+
+func TestWriteReportRejectsUnknownFormat(t *testing.T) {
+	s := newTestStore(t)
+	if err := writeReport(&bytes.Buffer{}, s, "year", ReportRange{}, 0); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestStatusLine(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	s.AddStream("Client", 1)
+	if got := statusLine(s); got != "idle" {
+		t.Fatalf("expected idle, got %q", got)
+	}
+
+	email, client := s.Streams[0].ID, s.Streams[1].ID
+	start := time.Now().Add(-62 * time.Minute)
+	s.Sessions = []store.Session{{
+		Start: start,
+		Spans: []store.Span{
+			{StreamID: email, Start: start},
+			{StreamID: client, Start: start.Add(time.Hour)},
+		},
+	}}
+	s.Streams[0].Active, s.Streams[1].Active = true, true
+	if got := statusLine(s); got != "● Email 1h 02m +1" {
+		t.Fatalf("unexpected status %q", got)
+	}
+}
+
+func TestWriteStreamJSON(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	s.AddStream("Client", 1)
+	email := s.Streams[0].ID
+	if err := s.AddPastSession(email, time.Now().Add(-3*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	s.ToggleStreamAt(email, time.Now().Add(-10*time.Minute))
+
+	var buf bytes.Buffer
+	if err := writeStreamJSON(&buf, s, "email"); err != nil {
+		t.Fatal(err)
+	}
+	var got StreamStatus
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != email || got.Name != "Email" || !got.Active || got.StartedAt == nil {
+		t.Fatalf("unexpected status %+v", got)
+	}
+	if got.ElapsedSeconds < 70*60 || got.ElapsedSeconds > 71*60 || got.CurrentRunSeconds < 10*60 || got.CurrentRunSeconds > 11*60 {
+		t.Fatalf("expected 1h10m in total and 10m in the current run, got %+v", got)
+	}
+
+	buf.Reset()
+	if err := writeStreamJSON(&buf, s, s.Streams[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"current_run_seconds": 0`) || strings.Contains(buf.String(), "started_at") {
+		t.Fatalf("expected a stopped stream looked up by ID, got %s", buf.String())
+	}
+
+	if err := writeStreamJSON(&buf, s, "Nope"); err == nil {
+		t.Fatal("expected an error for an unknown stream")
+	}
+	s.RenameStream(s.Streams[1].ID, email)
+	if err := writeStreamJSON(&buf, s, email); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("expected a name matching another stream's ID to be ambiguous, got %v", err)
+	}
+}
@@ -0,0 +1,2382 @@
+// Package store is urd's data model: streams, the wall-clock sessions they
+// are tracked in, and the urd.json file they persist to. It has no terminal
+// dependencies, so other programs can read and update a urd data file with
+// the same bookkeeping the TUI uses. Every mutation that starts or stops a
+// stream goes through Store methods, which keep sessions, spans and the
+// event log consistent; callers only need to Save afterwards.
+package store
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stream represents a named time-tracking category. Streams are toggleable
+// labels — actual time is tracked via Sessions (wall-clock periods). A stream
+// only records whether it's currently active and when the current activation
+// started, which is used to manage session boundaries.
+// DeletedAt is only set on streams sitting in the trash. Archived streams
+// keep their history but are hidden from the main list.
+// TargetSeconds is an optional time budget (e.g. hours committed to a
+// client); zero means no target. Pinned streams are kept above the
+// auto-sorted rest in the order the user arranged them (see MoveStream).
+// Color is a lipgloss color (an ANSI index like "4") for the stream's name;
+// empty keeps the default styling. Notes are free-form jottings about the
+// work, oldest first.
+// ResetAt is when the stream's counter was last zeroed (see ResetStream);
+// Elapsed counts only time after it. ExcludeFromTotal keeps a stream such as
+// breaks out of the TUI's total of stream time; it is still tracked and
+// still part of the wall clock.
+type Stream struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Active    bool       `json:"active"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Archived  bool       `json:"archived,omitempty"`
+	Pinned    bool       `json:"pinned,omitempty"`
+	Color     string     `json:"color,omitempty"`
+	Notes     []Note     `json:"notes,omitempty"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+
+	TargetSeconds    int64 `json:"target_seconds,omitempty"`
+	ExcludeFromTotal bool  `json:"exclude_from_total,omitempty"`
+}
+
+// Note is a short remark attached to a stream. At records when it was
+// written, which is enough to line it up with the session it was about.
+type Note struct {
+	At   time.Time `json:"at"`
+	Text string    `json:"text"`
+}
+
+// TargetProgress returns elapsed as a fraction of the stream's target and
+// whether a target is set at all. Elapsed is passed in because it is derived
+// from the store's sessions, not held on the stream. The fraction isn't
+// capped, so overshooting a target shows as more than 1.
+func (st Stream) TargetProgress(elapsed time.Duration) (float64, bool) {
+	if st.TargetSeconds <= 0 {
+		return 0, false
+	}
+	return elapsed.Seconds() / float64(st.TargetSeconds), true
+}
+
+// Session tracks a continuous wall-clock period during which at least one
+// stream was active. Sessions exist separately from streams so we can show
+// total wall-clock time even when the user switches between streams (which
+// would otherwise create gaps). End is a pointer so that nil represents an
+// ongoing session — this lets us detect unclean shutdowns (crash/force-quit)
+// on the next load, since the session will still be open.
+// Spans attribute the session's time to streams. Files written before spans
+// existed simply have none, and their time stays unattributed.
+type Session struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+	Spans []Span     `json:"spans,omitempty"`
+}
+
+// Span records one stream's activation within a session. A session holds
+// consecutive spans when the user switches streams and overlapping ones when
+// several streams run at once, so a stream's time is the sum of its spans
+// while wall clock remains the session length. A nil End means the span is
+// still running (or was left open by a crash and ends with its session).
+type Span struct {
+	StreamID string     `json:"stream_id"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+}
+
+// WindowSize is the last terminal size the TUI saw. It's persisted so the
+// first frame after launch can be laid out before Bubble Tea delivers the
+// initial WindowSizeMsg.
+type WindowSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Event is one entry in the store's append-only audit log. Sessions and
+// spans are the derived record that totals are computed from, and they can
+// be edited or deleted after the fact; events only ever accumulate, so the
+// log shows what actually happened and when, including the edits. At is the
+// effective time of the action — a backdated start is logged at the time it
+// was backdated to. StreamID is empty for session edits, which don't belong
+// to one stream. Detail is a short human-readable note (e.g. the old and
+// new name of a rename).
+type Event struct {
+	At       time.Time `json:"at"`
+	Type     string    `json:"type"`
+	StreamID string    `json:"stream_id,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// Event types.
+const (
+	EventStart  = "start"
+	EventStop   = "stop"
+	EventAdd    = "add"
+	EventDelete = "delete"
+	EventEdit   = "edit"
+)
+
+// Store is the root data structure persisted to urd.json. It owns all streams
+// and sessions. FilePath is tagged `json:"-"` so it stays out of the JSON file
+// — it's runtime-only state injected by LoadStore.
+// Version is the schema version of the file; see migrate. Files written
+// before it existed have no field and load as version 0.
+// LastActive records which streams were running before StopAll, enabling
+// ContinueAll to resume exactly the same set. It's cleared after use.
+// Paused is the same idea for Pause/Resume, kept separate so the two
+// workflows don't overwrite each other.
+// InterruptionID optionally names an "umbrella" stream that captures time
+// which would otherwise go untracked — see applyInterruptionCapture.
+// Mode is "" for the default multi-stream tracking or ModeFocus for a
+// classic single timer; see SetMode. SortMode picks the list order; see
+// SortStreams.
+// SessionCapMinutes is an opt-in limit on a single session's length; zero
+// disables it. See EnforceSessionCap.
+// RoundingMinutes is the default billing increment for reports (--round
+// overrides it); zero means exact.
+// Window remembers the terminal size between runs (see WindowSize).
+// DayStartHour is the local hour (0-23) at which a logical day begins, for
+// people whose working day runs past midnight; see dayKey.
+// Anchor, when set, starts the displayed totals over from that moment
+// without deleting anything; see SetAnchor.
+// Trash holds deleted streams so a delete can be undone; entries older than
+// TrashDays (default 30) are purged on load.
+// Events is the audit log; see Event.
+// Recovery is runtime-only too: set by LoadStore when the file on disk was
+// corrupt, nil otherwise. Repairs likewise lists what repairSessions fixed.
+// LastSavedAt is stamped by Save; it's the last moment urd is known to have
+// been running against this file. See StaleSince.
+// FileMode, like FilePath, is runtime-only: the permissions Save applies to
+// the data file. Zero means the historical default of 0644.
+// BackupCount is runtime-only as well: how many rotated backups Save keeps
+// (see rotateBackups). Zero, the default for a bare Store as in tests,
+// keeps none.
+type Store struct {
+	Version           int         `json:"version"`
+	Streams           []Stream    `json:"streams"`
+	Sessions          []Session   `json:"sessions"`
+	LastActive        []string    `json:"last_active,omitempty"`
+	Paused            []string    `json:"paused,omitempty"`
+	InterruptionID    string      `json:"interruption_id,omitempty"`
+	Mode              string      `json:"mode,omitempty"`
+	SortMode          string      `json:"sort_mode,omitempty"`
+	SessionCapMinutes int         `json:"session_cap_minutes,omitempty"`
+	RoundingMinutes   int         `json:"rounding_minutes,omitempty"`
+	Trash             []Stream    `json:"trash,omitempty"`
+	TrashDays         int         `json:"trash_days,omitempty"`
+	Window            *WindowSize `json:"window,omitempty"`
+	DayStartHour      int         `json:"day_start_hour,omitempty"`
+	Anchor            *time.Time  `json:"anchor,omitempty"`
+	Events            []Event     `json:"events,omitempty"`
+	LastSavedAt       *time.Time  `json:"last_saved_at,omitempty"`
+	FilePath          string      `json:"-"`
+	FileMode          os.FileMode `json:"-"`
+	BackupCount       int         `json:"-"`
+	Recovery          *Recovery   `json:"-"`
+	Repairs           []string    `json:"-"`
+}
+
+// newID generates a short random hex string for stream identification.
+// 3 bytes = 6 hex chars, giving 16M possible IDs. This is plenty for a
+// personal time tracker (collisions are negligible) while keeping the JSON
+// human-readable. We use crypto/rand over math/rand to avoid seeding concerns.
+func newID() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// DataPath decides which data file to use. An explicit --file flag wins,
+// then the URD_FILE environment variable, then "file" from the config file
+// (configPath). Without any of them, a urd.json in the working directory is
+// still used if it exists, since that was the only location before the
+// path was configurable. Otherwise the file lives in $XDG_DATA_HOME/urd
+// (falling back to ~/.local/share/urd), and the directory is created so the
+// first Save succeeds.
+func DataPath(flagPath, configPath string) (string, error) {
+	if flagPath != "" {
+		return flagPath, nil
+	}
+	if env := os.Getenv("URD_FILE"); env != "" {
+		return env, nil
+	}
+	if configPath != "" {
+		return configPath, nil
+	}
+	if _, err := os.Stat("urd.json"); err == nil {
+		return "urd.json", nil
+	}
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	dir = filepath.Join(dir, "urd")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "urd.json"), nil
+}
+
+// LoadStore reads the JSON file at path, or returns an empty Store if the file
+// doesn't exist yet (first run). A missing file is not an error because we
+// want a zero-config first launch — the file is created on the first Save().
+func LoadStore(path string) (*Store, error) {
+	return loadStore(path, false)
+}
+
+// LoadStoreReadOnly is LoadStore for callers that only display the data,
+// like --watch, which polls without holding the lock. It never touches the
+// file: one that can't be parsed is an error and stays where it is rather
+// than being moved aside, and a migration is applied in memory only.
+func LoadStoreReadOnly(path string) (*Store, error) {
+	return loadStore(path, true)
+}
+
+func loadStore(path string, readOnly bool) (*Store, error) {
+	s := &Store{FilePath: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.Version = currentVersion
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		if readOnly {
+			return nil, fmt.Errorf("the data file could not be read: %w", err)
+		}
+		rec, tmp, rerr := recoverCorrupt(path, err)
+		if rerr != nil {
+			return nil, rerr
+		}
+		s = &Store{FilePath: path, Recovery: rec}
+		if tmp == nil {
+			s.Version = currentVersion
+			return s, nil
+		}
+		// Already parsed once by recoverCorrupt, so this can't fail.
+		json.Unmarshal(tmp, s)
+	}
+	// Safety fallback: a stream can end up Active with no StartedAt if the
+	// JSON was hand-edited or if a bug wrote a partial state. Rather than
+	// refusing to load, we recover by setting StartedAt to now — the stream
+	// will just start counting from this moment.
+	now := time.Now()
+	for i := range s.Streams {
+		if s.Streams[i].Active && s.Streams[i].StartedAt == nil {
+			s.Streams[i].StartedAt = &now
+		}
+	}
+	migrated, err := s.migrate(now)
+	if err != nil {
+		return nil, err
+	}
+	s.PurgeTrash(now)
+	s.repairSessions(now)
+	s.syncSpans(now)
+	if migrated && !readOnly {
+		// --file-mode isn't applied until after loading, so keep the
+		// file's current permissions rather than loosening a private file
+		// to the default.
+		if info, err := os.Stat(path); err == nil {
+			s.FileMode = info.Mode().Perm()
+		}
+		err := s.Save()
+		s.FileMode = 0
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Recovery describes what LoadStore did about a data file it couldn't
+// parse, so the caller can tell the user instead of the data silently changing.
+type Recovery struct {
+	Err         error
+	CorruptPath string
+	FromTemp    bool
+}
+
+func (r *Recovery) String() string {
+	msg := fmt.Sprintf("the data file could not be read (%v) and was moved to %s; ", r.Err, r.CorruptPath)
+	if r.FromTemp {
+		return msg + "recovered from the last interrupted save"
+	}
+	return msg + "starting with an empty tracker"
+}
+
+// recoverCorrupt handles a data file that isn't valid JSON, typically one
+// truncated by a crash or a full disk. The bad file is never deleted: it's
+// renamed to <file>.corrupt.<timestamp> so it can be repaired by hand. If a
+// complete .tmp from an interrupted Save is lying next to it, that is the
+// newest consistent state and becomes the data file; its bytes are
+// returned for LoadStore to continue with. Otherwise tmp is nil and the
+// caller starts fresh.
+func recoverCorrupt(path string, cause error) (*Recovery, []byte, error) {
+	resolved, err := ResolveDataPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec := &Recovery{
+		Err:         cause,
+		CorruptPath: resolved + ".corrupt." + time.Now().Format("20060102-150405"),
+	}
+	if err := os.Rename(resolved, rec.CorruptPath); err != nil {
+		return nil, nil, fmt.Errorf("data file is corrupt (%v) and could not be moved aside: %w", cause, err)
+	}
+	tmpPath := resolved + ".tmp"
+	tmp, err := os.ReadFile(tmpPath)
+	if err != nil || json.Unmarshal(tmp, &Store{}) != nil {
+		return rec, nil, nil
+	}
+	if err := os.Rename(tmpPath, resolved); err != nil {
+		return rec, nil, nil
+	}
+	rec.FromTemp = true
+	return rec, tmp, nil
+}
+
+// currentVersion is the schema version this build reads and writes.
+const currentVersion = 1
+
+// migrations[v] upgrades a store from version v to v+1. New fields that
+// are fine as their zero value (colors, targets, pins) need no step; a step
+// is for data that has to be derived or reshaped so older files keep
+// meaning the same thing.
+var migrations = []func(s *Store, now time.Time){
+	// 0 → 1: the original schema had no per-stream spans. Closed sessions
+	// can't be attributed after the fact, but streams that are running
+	// get a span in the open session from their StartedAt so the current
+	// activation is counted.
+	func(s *Store, now time.Time) { s.syncSpans(now) },
+}
+
+// migrate brings a freshly loaded store up to currentVersion and reports
+// whether anything was upgraded, so LoadStore can rewrite the file once
+// instead of migrating on every start. A file from a newer urd is refused
+// rather than loaded, since saving it would silently drop fields this
+// build doesn't know about.
+func (s *Store) migrate(now time.Time) (bool, error) {
+	if s.Version > currentVersion {
+		return false, fmt.Errorf("data file is version %d, but this urd only understands up to version %d", s.Version, currentVersion)
+	}
+	from := s.Version
+	for s.Version < currentVersion {
+		migrations[s.Version](s, now)
+		s.Version++
+	}
+	return s.Version != from, nil
+}
+
+// Save writes the store to disk using an atomic write-to-temp-then-rename
+// pattern. This prevents data loss if the process is killed mid-write: we
+// either have the old complete file or the new complete file, never a
+// half-written one. MarshalIndent is used over Marshal so the JSON file
+// remains human-readable for manual inspection and debugging.
+// When FileMode is set, the temp file is chmod'ed explicitly before the
+// rename: WriteFile only applies its mode when creating a file (and then
+// through the umask), so a leftover .tmp could otherwise keep looser
+// permissions. The rename carries the mode over to the final file.
+// Before the new file replaces the old one, the old one may be copied into
+// the rotating backups; see rotateBackups. A failed backup doesn't stop the
+// save, but is reported once the data is safely written.
+func (s *Store) Save() error {
+	now := time.Now()
+	s.LastSavedAt = &now
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	mode := s.FileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	path, err := ResolveDataPath(s.FilePath)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if s.FileMode != 0 {
+		if err := os.Chmod(tmp, s.FileMode); err != nil {
+			return err
+		}
+	}
+	backupErr := s.rotateBackups(path, mode, now)
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return backupErr
+}
+
+// backupInterval is how old the newest backup must be before Save takes
+// another. Saves happen on nearly every keypress, so rotating on each one
+// would leave only the last few seconds of history in the backups.
+const backupInterval = time.Hour
+
+// rotateBackups copies the data file at path to path.bak.1, shifting older
+// backups up to path.bak.<BackupCount> and dropping the oldest, so a bad
+// write or a mistaken edit can be undone by hand from a recent copy. It
+// runs at most once per backupInterval, judged by the modification time
+// of path.bak.1, and does nothing while BackupCount is zero or before the
+// file exists. The file is copied rather than renamed so the data file is
+// never missing, even for a moment.
+func (s *Store) rotateBackups(path string, mode os.FileMode, now time.Time) error {
+	if s.BackupCount <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	backup := func(n int) string { return fmt.Sprintf("%s.bak.%d", path, n) }
+	if info, err := os.Stat(backup(1)); err == nil && now.Sub(info.ModTime()) < backupInterval {
+		return nil
+	}
+	for n := s.BackupCount; n > 1; n-- {
+		if err := os.Rename(backup(n-1), backup(n)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// Through a temp file, so a crash mid-copy can't leave a truncated
+	// backup as the newest one.
+	tmp := backup(1) + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if s.FileMode != 0 {
+		if err := os.Chmod(tmp, s.FileMode); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, backup(1))
+}
+
+// ResolveDataPath follows path if it is a symlink, so Save replaces the
+// link's target rather than the link itself. Users often symlink urd.json
+// into a synced or centralized directory, and renaming over the link would
+// silently swap it for a regular file. The temp file is created next to the
+// resolved target so the rename stays on one filesystem and remains atomic.
+// A missing file (first save) is returned unchanged.
+func ResolveDataPath(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		return "", err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return path, nil
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// Clone returns a deep copy of the store. Time pointers are copied by value
+// and slices get fresh backing arrays, so mutating the copy can never reach
+// back into the original. Undo snapshots are clones, and it's the safe way
+// to try an edit and throw it away. The copy keeps FilePath, so saving it
+// writes the same file; Recovery is shared since it's never modified.
+func (s *Store) Clone() *Store {
+	c := *s
+	c.Streams = cloneStreams(s.Streams)
+	c.Trash = cloneStreams(s.Trash)
+	c.LastActive = append([]string(nil), s.LastActive...)
+	c.Paused = append([]string(nil), s.Paused...)
+	c.Events = append([]Event(nil), s.Events...)
+	c.Repairs = append([]string(nil), s.Repairs...)
+	c.LastSavedAt = cloneTime(s.LastSavedAt)
+	c.Anchor = cloneTime(s.Anchor)
+	if s.Sessions != nil {
+		c.Sessions = make([]Session, len(s.Sessions))
+		for i, sess := range s.Sessions {
+			sess.End = cloneTime(sess.End)
+			if sess.Spans != nil {
+				spans := make([]Span, len(sess.Spans))
+				for j, sp := range sess.Spans {
+					sp.End = cloneTime(sp.End)
+					spans[j] = sp
+				}
+				sess.Spans = spans
+			}
+			c.Sessions[i] = sess
+		}
+	}
+	if s.Window != nil {
+		w := *s.Window
+		c.Window = &w
+	}
+	return &c
+}
+
+func cloneStreams(streams []Stream) []Stream {
+	if streams == nil {
+		return nil
+	}
+	out := make([]Stream, len(streams))
+	for i, st := range streams {
+		st.StartedAt = cloneTime(st.StartedAt)
+		st.DeletedAt = cloneTime(st.DeletedAt)
+		st.Notes = slices.Clone(st.Notes)
+		st.ResetAt = cloneTime(st.ResetAt)
+		out[i] = st
+	}
+	return out
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// AddStream inserts a new stream at position `at` in the slice. The position
+// parameter enables the o/O keybindings (add below/above cursor). Clamping
+// ensures out-of-range positions don't panic — they just append to the end.
+// A name already in use is rejected; see CheckName.
+func (s *Store) AddStream(name string, at int) error {
+	if err := s.CheckName("", name); err != nil {
+		return err
+	}
+	st := Stream{
+		ID:        newID(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	s.LogEvent(EventAdd, st.ID, st.CreatedAt, name)
+	if at < 0 {
+		at = 0
+	}
+	if at >= len(s.Streams) {
+		s.Streams = append(s.Streams, st)
+		return nil
+	}
+	// Splice insert: grow the slice by one, shift elements right, then place
+	// the new stream at the desired index.
+	s.Streams = append(s.Streams[:at+1], s.Streams[at:]...)
+	s.Streams[at] = st
+	return nil
+}
+
+// ImportNames creates a stream for every name in r, one per line, appended
+// to the end of the list. Surrounding whitespace is trimmed, and blank lines
+// and lines starting with # are ignored, so a hand-written list can carry
+// comments. Names that already exist (including earlier in the same input)
+// are skipped rather than treated as errors, which makes re-running an
+// import harmless. It returns how many streams were added; the caller saves.
+func (s *Store) ImportNames(r io.Reader) (int, error) {
+	added := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if s.CheckName("", name) != nil {
+			continue
+		}
+		if err := s.AddStream(name, len(s.Streams)); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, scanner.Err()
+}
+
+// CheckName rejects a name that another stream (any stream but id) already
+// uses, compared case-insensitively. "email" and "Email" would look like
+// two streams in reports while the CLI's case-insensitive lookup could only
+// ever reach one of them. Archived streams count, since they still show up
+// in reports; the trash doesn't, it's checked on restore instead.
+func (s *Store) CheckName(id, name string) error {
+	for _, st := range s.Streams {
+		if st.ID != id && strings.EqualFold(st.Name, name) {
+			return fmt.Errorf("a stream named %q already exists", st.Name)
+		}
+	}
+	return nil
+}
+
+// DeleteStream moves a stream into the trash rather than discarding it, so
+// a mistaken delete can be recovered with RestoreStream. The trashed copy is
+// always inactive — the caller is responsible for closing the session if the
+// stream was the last one running (see CloseCurrentSession).
+func (s *Store) DeleteStream(id string) {
+	if s.InterruptionID == id {
+		s.InterruptionID = ""
+	}
+	for i, st := range s.Streams {
+		if st.ID == id {
+			now := time.Now()
+			if st.Active {
+				s.LogEvent(EventStop, id, now, "")
+			}
+			s.LogEvent(EventDelete, id, now, st.Name)
+			st.Active = false
+			st.StartedAt = nil
+			st.DeletedAt = &now
+			s.Trash = append(s.Trash, st)
+			s.Streams = append(s.Streams[:i], s.Streams[i+1:]...)
+			s.syncSpans(now)
+			return
+		}
+	}
+}
+
+// MergeStreams folds stream srcID into dstID and removes the source, for
+// combining duplicates like "email" and "Email". Time lives in session
+// spans rather than a counter on the stream, so the merge relabels the
+// source's spans and unions them with the destination's in each session;
+// where both ran at once the overlap is counted once, not twice. A running
+// source has nothing to flush — its open span simply becomes the
+// destination's, and the destination is activated if it wasn't already.
+// The source's place in LastActive, Paused and InterruptionID passes to the
+// destination too. The source's notes join the destination's, still oldest
+// first. The destination keeps its own target; it takes the source's only
+// if it had none. The source is dropped outright rather than trashed, since
+// its history now belongs to the destination.
+func (s *Store) MergeStreams(srcID, dstID string) error {
+	if srcID == dstID {
+		return fmt.Errorf("cannot merge a stream into itself")
+	}
+	si, di := -1, -1
+	for i, st := range s.Streams {
+		switch st.ID {
+		case srcID:
+			si = i
+		case dstID:
+			di = i
+		}
+	}
+	if si < 0 || di < 0 {
+		return fmt.Errorf("stream not found")
+	}
+	now := time.Now()
+	src, dst := s.Streams[si], &s.Streams[di]
+	s.LogEvent(EventEdit, dstID, now, "merged "+src.Name)
+	s.LogEvent(EventDelete, srcID, now, "merged into "+dst.Name)
+	if src.Active {
+		if !dst.Active || (src.StartedAt != nil && (dst.StartedAt == nil || src.StartedAt.Before(*dst.StartedAt))) {
+			dst.StartedAt = src.StartedAt
+		}
+		dst.Active = true
+	}
+	if len(src.Notes) > 0 {
+		dst.Notes = append(dst.Notes, src.Notes...)
+		slices.SortStableFunc(dst.Notes, func(a, b Note) int { return a.At.Compare(b.At) })
+	}
+	if dst.TargetSeconds == 0 {
+		dst.TargetSeconds = src.TargetSeconds
+	}
+	for i := range s.Sessions {
+		mergeSpans(&s.Sessions[i], srcID, dstID)
+	}
+	if s.InterruptionID == srcID {
+		s.InterruptionID = dstID
+	}
+	s.LastActive = replaceID(s.LastActive, srcID, dstID)
+	s.Paused = replaceID(s.Paused, srcID, dstID)
+	s.Streams = slices.Delete(s.Streams, si, si+1)
+	s.syncSpans(now)
+	return nil
+}
+
+// mergeSpans relabels src's spans in sess as dst and coalesces dst's spans
+// that overlap or touch. An open span (nil End) extends to infinity, so it
+// swallows anything starting after it.
+func mergeSpans(sess *Session, srcID, dstID string) {
+	var keep, spans []Span
+	for _, sp := range sess.Spans {
+		switch sp.StreamID {
+		case srcID, dstID:
+			sp.StreamID = dstID
+			spans = append(spans, sp)
+		default:
+			keep = append(keep, sp)
+		}
+	}
+	if len(spans) == 0 {
+		return
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	merged := []Span{spans[0]}
+	for _, sp := range spans[1:] {
+		cur := &merged[len(merged)-1]
+		if cur.End != nil && sp.Start.After(*cur.End) {
+			merged = append(merged, sp)
+			continue
+		}
+		if cur.End != nil && (sp.End == nil || sp.End.After(*cur.End)) {
+			cur.End = sp.End
+		}
+	}
+	sess.Spans = append(keep, merged...)
+}
+
+// replaceID swaps from for to in ids, dropping the result if to is already
+// present so the list doesn't name a stream twice.
+func replaceID(ids []string, from, to string) []string {
+	if !slices.Contains(ids, from) {
+		return ids
+	}
+	if slices.Contains(ids, to) {
+		return slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return id == from })
+	}
+	out := slices.Clone(ids)
+	out[slices.Index(out, from)] = to
+	return out
+}
+
+// SplitStream is the inverse of MergeStreams: it creates a stream called
+// name right after id and moves the latest seconds of id's finished time
+// into it. The most recent time is the usual thing to split off ("the last
+// two hours were really the review"). Whole spans are relabelled and the
+// one straddling the cut is split in two, so sessions, and with them the
+// wall clock, are untouched: the two streams' times simply add up to what
+// the source had. A running span isn't finished time and stays with the
+// source. Asking for more than the source's finished time is an error
+// rather than quietly moving less.
+func (s *Store) SplitStream(id, name string, seconds int64) error {
+	i := s.indexOf(id)
+	if i < 0 {
+		return fmt.Errorf("stream not found")
+	}
+	if seconds <= 0 {
+		return fmt.Errorf("split amount must be positive")
+	}
+	type ref struct {
+		sess, span int
+		start, end time.Time
+	}
+	var refs []ref
+	var available time.Duration
+	for si, sess := range s.Sessions {
+		for pi, sp := range sess.Spans {
+			if sp.StreamID != id {
+				continue
+			}
+			// Clip like StreamHistory, so the amount matches what's shown.
+			start, end := sp.Start, sess.End
+			if sp.End != nil && (end == nil || sp.End.Before(*end)) {
+				end = sp.End
+			}
+			if start.Before(sess.Start) {
+				start = sess.Start
+			}
+			if end == nil || !end.After(start) {
+				continue
+			}
+			refs = append(refs, ref{si, pi, start, *end})
+			available += end.Sub(start)
+		}
+	}
+	want := time.Duration(seconds) * time.Second
+	if want > available {
+		return fmt.Errorf("%q has only %s of finished time", s.Streams[i].Name, available.Truncate(time.Second))
+	}
+	if err := s.AddStream(name, i+1); err != nil {
+		return err
+	}
+	newID := s.Streams[i+1].ID
+	s.LogEvent(EventEdit, id, time.Now(), fmt.Sprintf("split %s into %s", want, name))
+
+	sort.Slice(refs, func(a, b int) bool { return refs[a].start.After(refs[b].start) })
+	for _, r := range refs {
+		if want <= 0 {
+			break
+		}
+		sess := &s.Sessions[r.sess]
+		sp := &sess.Spans[r.span]
+		d := r.end.Sub(r.start)
+		if d <= want {
+			sp.StreamID, sp.Start, sp.End = newID, r.start, cloneTime(&r.end)
+			want -= d
+			continue
+		}
+		cut := r.end.Add(-want)
+		sp.Start, sp.End = r.start, &cut
+		sess.Spans = append(sess.Spans, Span{StreamID: newID, Start: cut, End: cloneTime(&r.end)})
+		want = 0
+	}
+	return nil
+}
+
+// RestoreStream moves a stream out of the trash and back to the end of the
+// stream list. It comes back inactive; SortStreams places it by creation
+// time like any other stream.
+func (s *Store) RestoreStream(id string) error {
+	for i, st := range s.Trash {
+		if st.ID == id {
+			if err := s.CheckName(id, st.Name); err != nil {
+				return fmt.Errorf("%w; rename it before restoring", err)
+			}
+			st.DeletedAt = nil
+			s.LogEvent(EventAdd, id, time.Now(), "restored from trash")
+			s.Streams = append(s.Streams, st)
+			s.Trash = append(s.Trash[:i], s.Trash[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("stream not in trash")
+}
+
+// PurgeTrash permanently drops trashed streams deleted more than TrashDays
+// ago (30 when unset) and returns how many were removed. It runs on every
+// load so the trash can't grow without bound.
+func (s *Store) PurgeTrash(now time.Time) int {
+	days := s.TrashDays
+	if days <= 0 {
+		days = 30
+	}
+	cutoff := now.AddDate(0, 0, -days)
+	kept := s.Trash[:0]
+	for _, st := range s.Trash {
+		if st.DeletedAt != nil && st.DeletedAt.Before(cutoff) {
+			s.LogEvent(EventDelete, st.ID, now, "purged from trash")
+			continue
+		}
+		kept = append(kept, st)
+	}
+	purged := len(s.Trash) - len(kept)
+	s.Trash = kept
+	return purged
+}
+
+// RenameStream changes a stream's name in place. Everything else about the
+// stream — ID, creation time, active state — is untouched, so fixing a typo
+// no longer means deleting the stream and losing its history. Renaming onto
+// another stream's name is rejected; changing only the case of a stream's
+// own name is fine.
+func (s *Store) RenameStream(id, name string) error {
+	if err := s.CheckName(id, name); err != nil {
+		return err
+	}
+	st := s.StreamByID(id)
+	if st == nil {
+		return fmt.Errorf("stream not found")
+	}
+	s.LogEvent(EventEdit, id, time.Now(), fmt.Sprintf("renamed %q to %q", st.Name, name))
+	st.Name = name
+	return nil
+}
+
+// SetTarget sets a stream's target time; zero or negative clears it.
+func (s *Store) SetTarget(id string, target time.Duration) {
+	st := s.StreamByID(id)
+	if st == nil {
+		return
+	}
+	st.TargetSeconds = max(int64(target.Seconds()), 0)
+	detail := "target cleared"
+	if st.TargetSeconds > 0 {
+		detail = "target " + target.Truncate(time.Second).String()
+	}
+	s.LogEvent(EventEdit, id, time.Now(), detail)
+}
+
+// AddNote appends a note to stream id. Blank notes are ignored.
+func (s *Store) AddNote(id, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	st := s.StreamByID(id)
+	if st == nil {
+		return
+	}
+	now := time.Now()
+	st.Notes = append(st.Notes, Note{At: now, Text: text})
+	s.LogEvent(EventEdit, id, now, "note added")
+}
+
+// ResetStream zeroes stream id's counter, like the reset button on a
+// stopwatch. Time lives in the sessions, which also make up the wall clock,
+// so nothing is deleted: the stream records when it was reset and Elapsed
+// counts from there. A running stream keeps running and counts up from
+// zero. Reports over a date range and the stream's history still include
+// the earlier time, since it was really worked.
+func (s *Store) ResetStream(id string) {
+	st := s.StreamByID(id)
+	if st == nil {
+		return
+	}
+	now := time.Now()
+	st.ResetAt = &now
+	s.LogEvent(EventEdit, id, now, "reset")
+}
+
+// ToggleExcludeFromTotal flips whether stream id counts toward the total of
+// stream time.
+func (s *Store) ToggleExcludeFromTotal(id string) {
+	st := s.StreamByID(id)
+	if st == nil {
+		return
+	}
+	st.ExcludeFromTotal = !st.ExcludeFromTotal
+	detail := "counted in total"
+	if st.ExcludeFromTotal {
+		detail = "excluded from total"
+	}
+	s.LogEvent(EventEdit, id, time.Now(), detail)
+}
+
+// SetColor sets the color used to render a stream's name; "" clears it.
+func (s *Store) SetColor(id, color string) {
+	st := s.StreamByID(id)
+	if st == nil {
+		return
+	}
+	st.Color = color
+	s.LogEvent(EventEdit, id, time.Now(), "color "+strconv.Quote(color))
+}
+
+// ArchiveStream hides a finished stream from the main list while keeping
+// its sessions and attribution intact. An archived stream can't keep
+// running unseen, so an active one is stopped first through the normal
+// toggle path, which closes the session if it was the last one running.
+func (s *Store) ArchiveStream(id string) {
+	st := s.StreamByID(id)
+	if st == nil {
+		return
+	}
+	if st.Active {
+		s.toggleStreamAt(id, time.Now())
+	}
+	st.Archived = true
+	s.LogEvent(EventEdit, id, time.Now(), "archived")
+}
+
+// MoveStream moves a stream delta places within the pinned block at the top
+// of the list. An unpinned stream is pinned first, landing at the bottom of
+// the block, which is all that keypress does. Moving a pinned stream down
+// past the end of the block unpins it, handing it back to the auto-sort, so
+// there's no separate unpin action to remember. Archived streams keep
+// their place at the end and can't be moved.
+func (s *Store) MoveStream(id string, delta int) {
+	s.SortStreams()
+	i := -1
+	pinned := 0
+	for j, st := range s.Streams {
+		if st.ID == id {
+			i = j
+		}
+		if st.Pinned && !st.Archived {
+			pinned++
+		}
+	}
+	if i < 0 || s.Streams[i].Archived || delta == 0 {
+		return
+	}
+	st := s.Streams[i]
+	if !st.Pinned {
+		st.Pinned = true
+		s.Streams = slices.Delete(s.Streams, i, i+1)
+		s.Streams = slices.Insert(s.Streams, pinned, st)
+		return
+	}
+	to := max(i+delta, 0)
+	if to >= pinned {
+		s.Streams[i].Pinned = false
+		s.SortStreams()
+		return
+	}
+	s.Streams = slices.Delete(s.Streams, i, i+1)
+	s.Streams = slices.Insert(s.Streams, to, st)
+}
+
+// UnarchiveStream returns an archived stream to the main list.
+func (s *Store) UnarchiveStream(id string) {
+	st := s.StreamByID(id)
+	if st == nil {
+		return
+	}
+	st.Archived = false
+	s.LogEvent(EventEdit, id, time.Now(), "unarchived")
+}
+
+// ModeFocus is the single-timer Store.Mode: starting a stream stops
+// whatever else is running, so at most one stream is ever active.
+const ModeFocus = "focus"
+
+// SetMode switches between multi-stream tracking ("") and focus mode.
+// Entering focus mode with several streams running keeps only the most
+// recently started one, so the one-at-a-time rule holds from the moment
+// it's turned on rather than from the next toggle.
+func (s *Store) SetMode(mode string) {
+	if s.Mode == mode {
+		return
+	}
+	now := time.Now()
+	s.Mode = mode
+	s.LogEvent(EventEdit, "", now, "mode "+strconv.Quote(mode))
+	if mode != ModeFocus {
+		return
+	}
+	keep := -1
+	var keepAt time.Time
+	for i, st := range s.Streams {
+		if !st.Active {
+			continue
+		}
+		var at time.Time
+		if st.StartedAt != nil {
+			at = *st.StartedAt
+		}
+		if keep < 0 || at.After(keepAt) {
+			keep, keepAt = i, at
+		}
+	}
+	for i := range s.Streams {
+		if i != keep && s.Streams[i].Active {
+			s.StopStream(i, now)
+		}
+	}
+	s.syncSpans(now)
+}
+
+// ToggleStream activates or deactivates a single stream by ID, using the
+// current time. See toggleStreamAt for the full documentation.
+func (s *Store) ToggleStream(id string) {
+	s.toggleStreamAt(id, time.Now())
+}
+
+// ToggleStreamAt activates or deactivates a single stream by ID, using the
+// provided startAt time instead of time.Now(). This lets the user backdate
+// a stream activation (e.g. "I actually started 5 minutes ago"). When
+// deactivating, startAt is ignored — we always use now.
+func (s *Store) ToggleStreamAt(id string, startAt time.Time) {
+	s.toggleStreamAt(id, startAt)
+}
+
+// EnsureActive activates the stream only if it isn't already running. Unlike
+// ToggleStream it is idempotent, which makes it safe to bind to a macro key or
+// a physical button: pressing it twice never accidentally stops tracking.
+// Unknown IDs are ignored.
+func (s *Store) EnsureActive(id string) {
+	if st := s.StreamByID(id); st != nil && !st.Active {
+		s.toggleStreamAt(id, time.Now())
+	}
+}
+
+// EnsureStopped is the complement of EnsureActive: it deactivates the stream
+// only if it is currently running, and is a no-op otherwise.
+func (s *Store) EnsureStopped(id string) {
+	if s.isActive(id) {
+		s.toggleStreamAt(id, time.Now())
+	}
+}
+
+// toggleStreamAt is the shared implementation for ToggleStream and
+// ToggleStreamAt. Session management is edge-triggered: we only open/close
+// a wall-clock session when the count of active streams crosses zero. This
+// means switching between streams (deactivate A, activate B) doesn't create
+// a gap in the wall-clock session — only going from "nothing active" to
+// "something active" (or vice versa) triggers a session boundary.
+// In focus mode, activating a stream first stops every other one. That is a
+// switch like FocusStream, so the session carries on unbroken. The others
+// stop as of now even when the start is backdated, rather than having their
+// history rewritten.
+func (s *Store) toggleStreamAt(id string, startAt time.Time) {
+	hadActive := s.HasActive()
+	if i := s.indexOf(id); i >= 0 {
+		activated := !s.Streams[i].Active
+		if activated {
+			s.stopOthersInFocus(i, time.Now())
+			s.startStream(i, startAt)
+		} else {
+			s.StopStream(i, time.Now())
+		}
+		s.applyInterruptionCapture(id, activated)
+	}
+	hasActive := s.HasActive()
+	if !hadActive && hasActive {
+		s.Sessions = append(s.Sessions, Session{Start: startAt})
+	} else if hadActive && !hasActive {
+		s.CloseCurrentSession()
+	}
+	s.syncSpans(time.Now())
+}
+
+// FocusStream makes id the only active stream in a single step. Every other
+// active stream is deactivated and the target activated (if it wasn't
+// already) without the active count ever dropping to zero, so an open
+// wall-clock session is reused instead of being closed and reopened at the
+// same instant. A new session is only opened if nothing was running.
+func (s *Store) FocusStream(id string) {
+	idx := s.indexOf(id)
+	if idx < 0 {
+		return
+	}
+	hadActive := s.HasActive()
+	now := time.Now()
+	for i := range s.Streams {
+		if i != idx && s.Streams[i].Active {
+			s.StopStream(i, now)
+		}
+	}
+	if !s.Streams[idx].Active {
+		s.startStream(idx, now)
+	}
+	if !hadActive {
+		s.Sessions = append(s.Sessions, Session{Start: now})
+	}
+	s.syncSpans(now)
+}
+
+// StopAllExcept deactivates every active stream other than id. If id is
+// running the session stays open, so this trims a multitasking set down to
+// one stream without a session boundary. If id wasn't running, nothing is
+// left active and the session is closed as with StopAll. Unlike StopAll it
+// doesn't touch LastActive: this is a refinement, not a break.
+func (s *Store) StopAllExcept(id string) {
+	hadActive := s.HasActive()
+	now := time.Now()
+	for i := range s.Streams {
+		if s.Streams[i].ID != id && s.Streams[i].Active {
+			s.StopStream(i, now)
+		}
+	}
+	if hadActive && !s.HasActive() {
+		s.closeSessionAt(now)
+	}
+	s.syncSpans(now)
+}
+
+// SetInterruptionStream designates the stream that captures otherwise-idle
+// time. Passing the current interruption stream's ID (or "") turns capture
+// off again.
+func (s *Store) SetInterruptionStream(id string) {
+	now := time.Now()
+	if s.InterruptionID == id {
+		s.LogEvent(EventEdit, id, now, "interruption stream off")
+		s.InterruptionID = ""
+		return
+	}
+	s.InterruptionID = id
+	if id != "" {
+		s.LogEvent(EventEdit, id, now, "interruption stream on")
+	}
+}
+
+// applyInterruptionCapture keeps the wall-clock session running through an
+// interruption. When the last "real" stream is deactivated, the configured
+// interruption stream is activated in its place, so the session stays open
+// and the gap is attributed to it instead of disappearing. Activating any
+// real stream releases the interruption stream again. Toggling the
+// interruption stream itself is never intercepted, so the user can always
+// stop it explicitly, and StopAll bypasses capture entirely.
+func (s *Store) applyInterruptionCapture(id string, activated bool) {
+	if s.InterruptionID == "" || id == s.InterruptionID {
+		return
+	}
+	idx := -1
+	for i := range s.Streams {
+		if s.Streams[i].ID == s.InterruptionID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return
+	}
+	if activated {
+		if s.Streams[idx].Active {
+			s.StopStream(idx, time.Now())
+		}
+		return
+	}
+	if !s.HasActive() {
+		s.startStream(idx, time.Now())
+	}
+}
+
+// StopAll pauses every active stream and records their IDs in LastActive.
+// This enables a stop/continue workflow: the user can pause everything
+// (e.g. for a meeting) and later resume the exact same set with ContinueAll.
+// LastActive is reset each time so it always reflects the most recent stop.
+func (s *Store) StopAll() {
+	s.LastActive = s.deactivateAll(time.Now())
+}
+
+// StopAllAt is StopAll backdated to `at`: the session and every running
+// span end there instead of now, so time after `at` is never counted. The
+// idle detector uses it to stop tracking at the moment activity ceased.
+// `at` is clamped to the open session's start so it can't end before it
+// began.
+func (s *Store) StopAllAt(at time.Time) {
+	if sess := s.openSession(); sess != nil && at.Before(sess.Start) {
+		at = sess.Start
+	}
+	s.LastActive = s.deactivateAll(at)
+}
+
+// ContinueAll resumes the streams that were running before the last StopAll.
+// LastActive is cleared after use — continue is a one-shot operation.
+func (s *Store) ContinueAll() {
+	if len(s.LastActive) == 0 {
+		return
+	}
+	s.activateAll(s.LastActive)
+	s.LastActive = nil
+}
+
+// Pause is StopAll with its own memory: the active set is kept in Paused
+// rather than LastActive, so a stop/continue cycle in between can't clobber
+// it. Pausing with nothing running leaves any earlier paused set alone.
+func (s *Store) Pause() {
+	if !s.HasActive() {
+		return
+	}
+	s.Paused = s.deactivateAll(time.Now())
+}
+
+// Resume restarts the streams recorded by Pause in a fresh session and
+// clears the paused set.
+func (s *Store) Resume() {
+	if len(s.Paused) == 0 {
+		return
+	}
+	s.activateAll(s.Paused)
+	s.Paused = nil
+}
+
+// deactivateAll stops every active stream, closes the session at `at` if
+// one was running, and returns the IDs that were stopped. Interruption
+// capture is deliberately bypassed: stopping everything means stopping
+// everything.
+func (s *Store) deactivateAll(at time.Time) []string {
+	hadActive := s.HasActive()
+	var stopped []string
+	for i := range s.Streams {
+		if s.Streams[i].Active {
+			stopped = append(stopped, s.Streams[i].ID)
+			s.StopStream(i, at)
+		}
+	}
+	if hadActive {
+		s.closeSessionAt(at)
+	}
+	return stopped
+}
+
+// activateAll starts the given streams, skipping any that were deleted or
+// archived in the meantime. A single `now` timestamp is captured and shared
+// across all resumed streams so they start from the exact same moment,
+// keeping time accounting consistent. In focus mode only the first of them
+// is resumed, replacing whatever is running.
+func (s *Store) activateAll(ids []string) {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	hadActive := s.HasActive()
+	now := time.Now()
+	for i := range s.Streams {
+		if set[s.Streams[i].ID] && !s.Streams[i].Active && !s.Streams[i].Archived {
+			s.stopOthersInFocus(i, now)
+			s.startStream(i, now)
+			if s.Mode == ModeFocus {
+				break
+			}
+		}
+	}
+	if !hadActive && s.HasActive() {
+		s.Sessions = append(s.Sessions, Session{Start: now})
+	}
+	s.syncSpans(now)
+}
+
+// startStream marks stream i active from at and logs the start. Every
+// activation goes through here (and every deactivation through StopStream)
+// so the event log can't miss a transition; session and span bookkeeping
+// stays with the callers.
+func (s *Store) startStream(i int, at time.Time) {
+	t := at
+	s.Streams[i].Active = true
+	s.Streams[i].StartedAt = &t
+	s.LogEvent(EventStart, s.Streams[i].ID, at, "")
+}
+
+// stopOthersInFocus stops every active stream except i when the store is
+// in focus mode, ahead of starting i. It does nothing in multi mode.
+func (s *Store) stopOthersInFocus(i int, at time.Time) {
+	if s.Mode != ModeFocus {
+		return
+	}
+	for j := range s.Streams {
+		if j != i && s.Streams[j].Active {
+			s.StopStream(j, at)
+		}
+	}
+}
+
+// StopStream marks stream i inactive and logs the stop at `at`.
+func (s *Store) StopStream(i int, at time.Time) {
+	s.Streams[i].Active = false
+	s.Streams[i].StartedAt = nil
+	s.LogEvent(EventStop, s.Streams[i].ID, at, "")
+}
+
+// LogEvent appends an entry to the audit log.
+func (s *Store) LogEvent(typ, streamID string, at time.Time, detail string) {
+	s.Events = append(s.Events, Event{At: at, Type: typ, StreamID: streamID, Detail: detail})
+}
+
+// EventsForStream returns the logged events for stream id, oldest first.
+func (s *Store) EventsForStream(id string) []Event {
+	var events []Event
+	for _, e := range s.Events {
+		if e.StreamID == id {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// CloseCurrentSession finds the most recent open session and sets its End
+// to now, closing any spans still running inside it. We search backwards
+// because the open session is always the last one — earlier sessions are
+// already closed. The reverse scan is a defensive choice in case of data
+// corruption.
+func (s *Store) CloseCurrentSession() {
+	s.closeSessionAt(time.Now())
+}
+
+// closeSessionAt is CloseCurrentSession with an explicit end time, used when
+// the real end lies in the past (e.g. idle detection).
+func (s *Store) closeSessionAt(at time.Time) {
+	for i := len(s.Sessions) - 1; i >= 0; i-- {
+		if s.Sessions[i].End == nil {
+			s.Sessions[i].End = endAt(s.Sessions[i].Start, at)
+			closeSpans(&s.Sessions[i], at)
+			return
+		}
+	}
+}
+
+// closeSpans ends every running span in sess at `at`.
+func closeSpans(sess *Session, at time.Time) {
+	for i := range sess.Spans {
+		if sess.Spans[i].End == nil {
+			sess.Spans[i].End = endAt(sess.Spans[i].Start, at)
+		}
+	}
+}
+
+// endAt is the end to record for a session or span that started at start
+// and is being closed at at. Normally that's at, but if the system clock
+// was stepped backward while it ran (NTP, a resync after sleep), at can be
+// earlier than start. The range is then recorded as empty rather than
+// ending before it starts, which every total would count as negative time.
+// The time that ran before the step can't be recovered either way.
+func endAt(start, at time.Time) *time.Time {
+	if at.Before(start) {
+		at = start
+	}
+	return &at
+}
+
+// repairSessions fixes the two ways the open session and the streams' Active
+// flags can disagree after a crash or a hand edit, recording what it did in
+// Repairs so it isn't silent:
+//
+//   - An open session with nothing running would add wall-clock time that
+//     belongs to no stream for as long as it stays open. It is closed where
+//     tracking evidently stopped: at its last span's end, or, with no spans
+//     at all, the last save.
+//   - Active streams with no open session would show a running dot but
+//     accrue no time. A session is opened for them from their earliest
+//     StartedAt, kept after the previous session so sessions don't overlap.
+//
+// An open session with streams running is consistent, however old: quitting
+// deliberately leaves tracking on. StaleSince covers that case instead.
+func (s *Store) repairSessions(now time.Time) {
+	sess := s.openSession()
+	switch {
+	case sess != nil && !s.HasActive():
+		end := sess.Start
+		for _, sp := range sess.Spans {
+			last := sp.Start
+			if sp.End != nil {
+				last = *sp.End
+			}
+			if last.After(end) {
+				end = last
+			}
+		}
+		if len(sess.Spans) == 0 && s.LastSavedAt != nil && s.LastSavedAt.After(end) {
+			end = *s.LastSavedAt
+		}
+		if end.After(now) {
+			end = now
+		}
+		s.closeSessionAt(end)
+		s.Repairs = append(s.Repairs, "closed a session left open with nothing running, as of "+end.Format("2006-01-02 15:04"))
+	case sess == nil && s.HasActive():
+		start := now
+		for _, st := range s.Streams {
+			if st.Active && st.StartedAt != nil && st.StartedAt.Before(start) {
+				start = *st.StartedAt
+			}
+		}
+		for _, prev := range s.Sessions {
+			if prev.End != nil && prev.End.After(start) {
+				start = *prev.End
+			}
+		}
+		for i := range s.Streams {
+			if s.Streams[i].Active && (s.Streams[i].StartedAt == nil || s.Streams[i].StartedAt.Before(start)) {
+				t := start
+				s.Streams[i].StartedAt = &t
+			}
+		}
+		s.Sessions = append(s.Sessions, Session{Start: start})
+		s.Repairs = append(s.Repairs, "reopened the session for streams left running without one, from "+start.Format("2006-01-02 15:04"))
+	}
+}
+
+// Repair fixes what a hand edit can leave behind that LoadStore doesn't
+// repair by itself, and describes each fix. LoadStore stays lenient and
+// doesn't call it: these states load fine but skew every total, and
+// rewriting someone's sessions should be asked for (see urd --repair).
+//
+//   - A session or span that ends before it starts would count negative
+//     time. Its end is clamped to its start, leaving it empty rather than
+//     guessing which of the two times was mistyped.
+//   - A span naming a stream that is neither in the list nor the trash can
+//     never be shown or restored, so it is dropped. Trashed streams keep
+//     their spans for RestoreStream.
+func (s *Store) Repair() []string {
+	known := make(map[string]bool)
+	for _, st := range slices.Concat(s.Streams, s.Trash) {
+		known[st.ID] = true
+	}
+	var fixed []string
+	for i := range s.Sessions {
+		sess := &s.Sessions[i]
+		when := sess.Start.Local().Format("2006-01-02 15:04")
+		if sess.End != nil && sess.End.Before(sess.Start) {
+			sess.End = cloneTime(&sess.Start)
+			fixed = append(fixed, "the session at "+when+" ended before it started; it now has zero length")
+		}
+		kept := sess.Spans[:0]
+		for _, sp := range sess.Spans {
+			if !known[sp.StreamID] {
+				fixed = append(fixed, fmt.Sprintf("dropped time for unknown stream %q from the session at %s", sp.StreamID, when))
+				continue
+			}
+			if sp.End != nil && sp.End.Before(sp.Start) {
+				sp.End = cloneTime(&sp.Start)
+				fixed = append(fixed, "a span in the session at "+when+" ended before it started; it now has zero length")
+			}
+			kept = append(kept, sp)
+		}
+		sess.Spans = kept
+	}
+	return fixed
+}
+
+// staleSessionAfter is how long streams can have been running without a
+// single save before StaleSince asks whether they were really meant to.
+const staleSessionAfter = 12 * time.Hour
+
+// StaleSince reports the last save time when streams are running but
+// nothing has saved the file for longer than staleSessionAfter. That's
+// what a crash mid-tracking looks like: the clock kept running with nobody
+// at it. It can also be a timer deliberately left on overnight, which is
+// why this only asks (see the TUI's startup prompt) rather than stopping
+// anything on its own.
+func (s *Store) StaleSince(now time.Time) (time.Time, bool) {
+	sess := s.openSession()
+	if sess == nil || !s.HasActive() || s.LastSavedAt == nil {
+		return time.Time{}, false
+	}
+	if now.Sub(*s.LastSavedAt) <= staleSessionAfter || !s.LastSavedAt.After(sess.Start) {
+		return time.Time{}, false
+	}
+	return *s.LastSavedAt, true
+}
+
+// CurrentSessionDuration is how long the open session has been running,
+// i.e. the current sitting, or zero when nothing is being tracked. Unlike
+// the wall clock it ignores earlier sessions and the anchor.
+func (s *Store) CurrentSessionDuration() time.Duration {
+	sess := s.openSession()
+	if sess == nil {
+		return 0
+	}
+	return max(time.Since(sess.Start), 0)
+}
+
+// LastActivity is when something was last being tracked: now if a session
+// is open, otherwise the latest end of any session. ok is false when there
+// are no sessions at all. Sessions are scanned rather than trusting their
+// order, since hand edits and AddPastSession can leave them out of order.
+func (s *Store) LastActivity() (time.Time, bool) {
+	if s.openSession() != nil {
+		return time.Now(), true
+	}
+	var last time.Time
+	for _, sess := range s.Sessions {
+		if sess.End != nil && sess.End.After(last) {
+			last = *sess.End
+		}
+	}
+	return last, !last.IsZero()
+}
+
+// openSession returns the most recent open session, or nil if nothing is
+// being tracked.
+func (s *Store) openSession() *Session {
+	for i := len(s.Sessions) - 1; i >= 0; i-- {
+		if s.Sessions[i].End == nil {
+			return &s.Sessions[i]
+		}
+	}
+	return nil
+}
+
+// syncSpans brings the open session's spans in line with the streams'
+// Active flags: a running span whose stream is no longer active is closed
+// at `at`, and an active stream without a running span gets one starting at
+// its StartedAt. Every mutation calls this once at the end instead of
+// threading span bookkeeping through each branch, so attribution stays
+// correct whatever mix of toggle, focus, capture or continue produced the
+// new state.
+func (s *Store) syncSpans(at time.Time) {
+	sess := s.openSession()
+	if sess == nil {
+		return
+	}
+	running := make(map[string]bool)
+	for i := range sess.Spans {
+		sp := &sess.Spans[i]
+		if sp.End != nil {
+			continue
+		}
+		if s.isActive(sp.StreamID) {
+			running[sp.StreamID] = true
+			continue
+		}
+		sp.End = endAt(sp.Start, at)
+	}
+	for _, st := range s.Streams {
+		if !st.Active || running[st.ID] {
+			continue
+		}
+		start := at
+		if st.StartedAt != nil {
+			start = *st.StartedAt
+		}
+		sess.Spans = append(sess.Spans, Span{StreamID: st.ID, Start: start})
+	}
+}
+
+// SplitSession closes the open session at `at` and opens a new one starting
+// at the same instant, so wall-clock time is unchanged but no longer sits in
+// a single interval. Active streams keep running; any StartedAt earlier than
+// the split is moved up to it so each stream's activation belongs to the new
+// session. Returns false if there is no open session or `at` doesn't fall
+// inside it.
+func (s *Store) SplitSession(at time.Time) bool {
+	for i := len(s.Sessions) - 1; i >= 0; i-- {
+		if s.Sessions[i].End != nil {
+			continue
+		}
+		if !at.After(s.Sessions[i].Start) {
+			return false
+		}
+		end := at
+		s.Sessions[i].End = &end
+		closeSpans(&s.Sessions[i], at)
+		s.Sessions = append(s.Sessions, Session{Start: at})
+		for j := range s.Streams {
+			st := &s.Streams[j]
+			if st.Active && st.StartedAt != nil && st.StartedAt.Before(at) {
+				t := at
+				st.StartedAt = &t
+			}
+		}
+		s.syncSpans(at)
+		return true
+	}
+	return false
+}
+
+// EnforceSessionCap splits the open session into SessionCapMinutes-long
+// pieces once it reaches the cap, so one forgotten all-day session doesn't
+// dominate reports. It's called from the tick loop and loops because the
+// app may have been closed for several cap lengths. Returns whether any
+// split happened so the caller knows to save.
+func (s *Store) EnforceSessionCap(now time.Time) bool {
+	if s.SessionCapMinutes <= 0 {
+		return false
+	}
+	limit := time.Duration(s.SessionCapMinutes) * time.Minute
+	split := false
+	for {
+		last := len(s.Sessions) - 1
+		if last < 0 || s.Sessions[last].End != nil {
+			return split
+		}
+		start := s.Sessions[last].Start
+		if now.Sub(start) < limit {
+			return split
+		}
+		if !s.SplitSession(start.Add(limit)) {
+			return split
+		}
+		split = true
+	}
+}
+
+// TotalWallClock returns the total non-overlapping wall-clock time spent
+// tracking, counted from the Anchor if one is set.
+func (s *Store) TotalWallClock() time.Duration {
+	return s.WallClockSince(time.Time{})
+}
+
+// WallClockSince is TotalWallClock counting only from since, or from the
+// Anchor if that is later.
+func (s *Store) WallClockSince(since time.Time) time.Duration {
+	return s.WallClockBetween(s.countFrom(since), time.Time{})
+}
+
+// countFrom returns since, moved up to the Anchor if that is later. The
+// totals the TUI shows all start from it; WallClockBetween and
+// StreamTimeBetween don't, so reports over an explicit range still see
+// everything.
+func (s *Store) countFrom(since time.Time) time.Time {
+	if s.Anchor != nil && s.Anchor.After(since) {
+		return *s.Anchor
+	}
+	return since
+}
+
+// SetAnchor makes the totals count from at onward, for starting fresh after
+// months of history without losing it; nil counts everything again.
+// Sessions before the anchor are kept, and sessions running across it count
+// only their part after it.
+func (s *Store) SetAnchor(at *time.Time) {
+	s.Anchor = cloneTime(at)
+	detail := "anchor cleared"
+	if at != nil {
+		detail = "anchor " + at.Format(time.RFC3339)
+	}
+	s.LogEvent(EventEdit, "", time.Now(), detail)
+}
+
+// WallClockByDay returns wall-clock time per logical day (see dayKey),
+// keyed by YYYY-MM-DD. Sessions that cross a day boundary are split so each
+// day only gets the part that happened on it; open sessions count up to now.
+// Days with no tracked time are absent from the map.
+func (s *Store) WallClockByDay() map[string]time.Duration {
+	now := time.Now()
+	days := make(map[string]time.Duration)
+	for _, sess := range s.Sessions {
+		end := now
+		if sess.End != nil {
+			end = *sess.End
+		}
+		s.splitByDay(sess.Start, end, func(day string, d time.Duration) {
+			days[day] += d
+		})
+	}
+	return days
+}
+
+// dayKey names the logical day t belongs to, as YYYY-MM-DD. It is the one
+// place that decides what "a day" means: local time, starting at
+// DayStartHour rather than midnight, so with DayStartHour 4 a session at
+// 02:00 on the 10th counts toward the 9th. Every per-day split and report
+// goes through it (via DayStart) so they always agree.
+func (s *Store) dayKey(t time.Time) string {
+	return s.DayStart(t).Format("2006-01-02")
+}
+
+// DayStart returns the moment the logical day containing t began.
+func (s *Store) DayStart(t time.Time) time.Time {
+	t = t.Local()
+	start := s.DayOn(t, 0)
+	if t.Before(start) {
+		start = s.DayOn(t, -1)
+	}
+	return start
+}
+
+// DayOn returns the start of the logical day on t's calendar date, shifted
+// by offset days. It uses time.Date rather than adding 24h so days that are
+// 23 or 25 hours long (DST changes) keep their boundary at the same hour.
+// Out-of-range hours in the file fall back to midnight.
+func (s *Store) DayOn(t time.Time, offset int) time.Time {
+	hour := s.DayStartHour
+	if hour < 0 || hour > 23 {
+		hour = 0
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()+offset, hour, 0, 0, 0, t.Location())
+}
+
+// splitByDay walks [start, end) one logical day at a time and calls fn with
+// each day's key and the portion of the interval that falls on it.
+func (s *Store) splitByDay(start, end time.Time, fn func(day string, d time.Duration)) {
+	start, end = start.Local(), end.Local()
+	for start.Before(end) {
+		day := s.DayStart(start)
+		next := s.DayOn(day, 1)
+		if next.After(end) {
+			next = end
+		}
+		fn(s.dayKey(start), next.Sub(start))
+		start = next
+	}
+}
+
+// Elapsed returns the total time stream id has been active, summed from its
+// spans across all sessions and counting running spans up to now. Because
+// overlapping streams each get the full overlap, the sum over all streams
+// can exceed TotalWallClock. Time before the stream was last reset, or
+// before the Anchor, isn't counted.
+func (s *Store) Elapsed(id string) time.Duration {
+	return s.ElapsedSince(id, time.Time{})
+}
+
+// ElapsedSince is Elapsed counting only from since, or from the stream's
+// last reset or the Anchor if either is later, e.g. for today's time on the
+// counter.
+func (s *Store) ElapsedSince(id string, since time.Time) time.Duration {
+	since = s.countFrom(since)
+	for _, st := range slices.Concat(s.Streams, s.Trash) {
+		if st.ID == id && st.ResetAt != nil && st.ResetAt.After(since) {
+			since = *st.ResetAt
+		}
+	}
+	return s.StreamTimeBetween(id, since, time.Time{})
+}
+
+// StreamTimeByDay returns how long stream id was active on each logical
+// day, keyed by YYYY-MM-DD like WallClockByDay. It sums the
+// stream's spans, so two streams running at once each get the full overlap
+// and their totals can exceed the day's wall clock.
+func (s *Store) StreamTimeByDay(id string) map[string]time.Duration {
+	days := make(map[string]time.Duration)
+	s.streamIntervals(id, func(start, end time.Time) {
+		s.splitByDay(start, end, func(day string, d time.Duration) {
+			days[day] += d
+		})
+	})
+	return days
+}
+
+// streamIntervals calls fn for every interval during which stream id was
+// active, with running ones counted up to now. See StreamHistory.
+func (s *Store) streamIntervals(id string, fn func(start, end time.Time)) {
+	now := time.Now()
+	for _, r := range s.StreamHistory(id) {
+		end := now
+		if r.End != nil {
+			end = *r.End
+		}
+		if end.After(r.Start) {
+			fn(r.Start, end)
+		}
+	}
+}
+
+// StreamRun is one continuous stretch of a single stream, as shown in its
+// history. End is nil while the stream is still running.
+type StreamRun struct {
+	Start time.Time
+	End   *time.Time
+}
+
+// StreamHistory returns every stretch during which stream id was active,
+// oldest first. Spans are clipped to their session, so editing a session's
+// times in the session view implicitly trims the attribution inside it. A
+// span left open ends with its session; only a span in the open session is
+// still running. Closed stretches of zero length (a stream toggled twice
+// within a clipped range) are dropped.
+func (s *Store) StreamHistory(id string) []StreamRun {
+	var runs []StreamRun
+	for _, sess := range s.Sessions {
+		for _, sp := range sess.Spans {
+			if sp.StreamID != id {
+				continue
+			}
+			if r, ok := spanRun(sess, sp); ok {
+				runs = append(runs, r)
+			}
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Start.Before(runs[j].Start) })
+	return runs
+}
+
+// HasRecordedTime reports whether stream id has any history at all, the
+// same as a non-empty StreamHistory but without collecting and sorting
+// every run: it stops at the first one. The list asks this of every stream
+// on every tick.
+func (s *Store) HasRecordedTime(id string) bool {
+	for _, sess := range s.Sessions {
+		for _, sp := range sess.Spans {
+			if sp.StreamID != id {
+				continue
+			}
+			if _, ok := spanRun(sess, sp); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// spanRun clips sp to its session as StreamHistory describes, reporting
+// false for a closed stretch of zero length.
+func spanRun(sess Session, sp Span) (StreamRun, bool) {
+	start, end := sp.Start, sess.End
+	if sp.End != nil && (end == nil || sp.End.Before(*end)) {
+		end = sp.End
+	}
+	if start.Before(sess.Start) {
+		start = sess.Start
+	}
+	if end != nil && !end.After(start) {
+		return StreamRun{}, false
+	}
+	return StreamRun{Start: start, End: cloneTime(end)}, true
+}
+
+// WallClockBetween returns the wall-clock time tracked inside [since, until).
+// Sessions straddling either boundary are clipped to it rather than counted
+// whole or dropped. A zero since or until leaves that side unbounded; open
+// sessions count up to now.
+func (s *Store) WallClockBetween(since, until time.Time) time.Duration {
+	now := time.Now()
+	var total time.Duration
+	for _, sess := range s.Sessions {
+		start, end := sess.Start, now
+		if sess.End != nil {
+			end = *sess.End
+		}
+		if !since.IsZero() && start.Before(since) {
+			start = since
+		}
+		if !until.IsZero() && end.After(until) {
+			end = until
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total.Truncate(time.Second)
+}
+
+// StreamTimeBetween returns how long stream id was active inside
+// [since, until), clipping spans at the boundaries like WallClockBetween.
+func (s *Store) StreamTimeBetween(id string, since, until time.Time) time.Duration {
+	var total time.Duration
+	s.streamIntervals(id, func(start, end time.Time) {
+		if !since.IsZero() && start.Before(since) {
+			start = since
+		}
+		if !until.IsZero() && end.After(until) {
+			end = until
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	})
+	return total.Truncate(time.Second)
+}
+
+// AddPastTime creates a closed session for a completed time block without
+// activating any stream. This is for recording work that happened entirely
+// in the past (e.g. a meeting from 10:00–10:45 that the user forgot to track).
+func (s *Store) AddPastTime(start, end time.Time) {
+	s.Sessions = append(s.Sessions, Session{Start: start, End: &end})
+	s.LogEvent(EventEdit, "", time.Now(), "session added "+formatSpan(start, &end))
+}
+
+// EditSeconds corrects stream id's recorded time by delta seconds, for
+// tracking that was forgotten or left running. A stream has no counter to
+// adjust, its time being the sum of its spans, so the edit is made to
+// sessions and the wall clock moves with the stream:
+//
+//   - Added time becomes a closed session holding one span for the stream
+//     (AddPastSession), in the latest stretch of the past no other session
+//     covers.
+//   - Removed time comes off the stream's most recent time. A running
+//     stream is stopped delta ago (or where its run began, if later), and
+//     whatever is left is trimmed from the end of its finished runs, newest
+//     first, dropping the runs it uses up. A trimmed session shrinks to the
+//     spans left in it and disappears when none are.
+//
+// Taking off more than the stream has stops at zero rather than failing;
+// zero is what Elapsed shows, so time before a reset or the Anchor is left
+// alone. Time recorded before sessions had spans belongs to no stream and is
+// never touched either.
+func (s *Store) EditSeconds(id string, delta int64) error {
+	st := s.StreamByID(id)
+	if st == nil {
+		return fmt.Errorf("stream not found")
+	}
+	now := time.Now()
+	d := time.Duration(delta) * time.Second
+	if d > 0 {
+		return s.AddPastSession(id, s.latestGap(d, now), d)
+	}
+	left, removed := -d, time.Duration(0)
+	if st.Active {
+		// Stop no earlier than the run began, nor before what Elapsed
+		// counts from, so the stream's time bottoms out at zero.
+		floor := s.countFrom(time.Time{})
+		if st.ResetAt != nil && st.ResetAt.After(floor) {
+			floor = *st.ResetAt
+		}
+		if st.StartedAt != nil && st.StartedAt.After(floor) {
+			floor = *st.StartedAt
+		}
+		if sess := s.openSession(); sess != nil && sess.Start.After(floor) {
+			floor = sess.Start
+		}
+		at := now.Add(-left)
+		if at.Before(floor) {
+			at = floor
+		}
+		removed = now.Sub(at)
+		left -= removed
+		s.stopAt(id, at, now)
+	}
+	left = min(left, s.Elapsed(id))
+	for left > 0 {
+		si, pi, run := s.lastRun(id)
+		if si < 0 {
+			break
+		}
+		sess := &s.Sessions[si]
+		if length := run.End.Sub(run.Start); length <= left {
+			sess.Spans = append(sess.Spans[:pi], sess.Spans[pi+1:]...)
+			removed += length
+			left -= length
+		} else {
+			cut := run.End.Add(-left)
+			sess.Spans[pi].End = &cut
+			removed += left
+			left = 0
+		}
+		s.fitSession(si)
+	}
+	s.LogEvent(EventEdit, id, now, fmt.Sprintf("took %s off", removed.Round(time.Second)))
+	return nil
+}
+
+// stopAt stops stream id as of `at`, an earlier moment than now, as though
+// it had been stopped then. If nothing else is running the session closes
+// too, at the latest point any of its spans still reaches.
+func (s *Store) stopAt(id string, at, now time.Time) {
+	s.StopStream(s.indexOf(id), at)
+	sess := s.openSession()
+	if sess == nil {
+		return
+	}
+	for i := range sess.Spans {
+		if sp := &sess.Spans[i]; sp.StreamID == id && sp.End == nil {
+			sp.End = endAt(sp.Start, at)
+		}
+	}
+	if s.HasActive() {
+		return
+	}
+	sess.End = endAt(sess.Start, now)
+	closeSpans(sess, now)
+	s.fitSession(len(s.Sessions) - 1)
+}
+
+// lastRun locates stream id's finished run that ends latest, returning its
+// session and span indices and the run clipped to its session (spanRun).
+// The indices are -1 when the stream has no finished time.
+func (s *Store) lastRun(id string) (si, pi int, run StreamRun) {
+	si, pi = -1, -1
+	for i, sess := range s.Sessions {
+		for j, sp := range sess.Spans {
+			if sp.StreamID != id {
+				continue
+			}
+			r, ok := spanRun(sess, sp)
+			if !ok || r.End == nil {
+				continue
+			}
+			if si < 0 || r.End.After(*run.End) {
+				si, pi, run = i, j, r
+			}
+		}
+	}
+	return si, pi, run
+}
+
+// fitSession shrinks the closed session at index i to the spans left in
+// it after EditSeconds took time away, dropping spans cut to nothing and
+// removing the session when none are left. Open sessions are left alone:
+// something is still running in them.
+func (s *Store) fitSession(i int) {
+	sess := &s.Sessions[i]
+	if sess.End == nil {
+		return
+	}
+	sess.Spans = slices.DeleteFunc(sess.Spans, func(sp Span) bool {
+		_, ok := spanRun(*sess, sp)
+		return !ok
+	})
+	if len(sess.Spans) == 0 {
+		s.Sessions = append(s.Sessions[:i], s.Sessions[i+1:]...)
+		return
+	}
+	var start, end time.Time
+	for j, sp := range sess.Spans {
+		spEnd := *sess.End
+		if sp.End != nil && sp.End.Before(spEnd) {
+			spEnd = *sp.End
+		}
+		if j == 0 || sp.Start.Before(start) {
+			start = sp.Start
+		}
+		if j == 0 || spEnd.After(end) {
+			end = spEnd
+		}
+	}
+	if start.After(sess.Start) {
+		sess.Start = start
+	}
+	if end.Before(*sess.End) {
+		sess.End = &end
+	}
+}
+
+// latestGap returns the start of the most recent stretch of dur, ending no
+// later than now, that no session covers: right before the open session
+// when there is one, otherwise ending now.
+func (s *Store) latestGap(dur time.Duration, now time.Time) time.Time {
+	end := now
+	for moved := true; moved; {
+		moved = false
+		for _, sess := range s.Sessions {
+			sessEnd := now
+			if sess.End != nil {
+				sessEnd = *sess.End
+			}
+			if end.Add(-dur).Before(sessEnd) && end.After(sess.Start) {
+				end = sess.Start
+				moved = true
+			}
+		}
+	}
+	return end.Add(-dur)
+}
+
+// ExportCSV writes one row per stream, in display order, with the columns
+// name, seconds, hours, created_at and active, followed by a Total row. The
+// figures are each stream's exact Elapsed time, so a spreadsheet sees the
+// same numbers the TUI shows; hours is a two-place decimal of the same
+// seconds. The total sums the rows rather than reporting wall clock, since
+// that's the figure an invoice adds up. An empty store still gets the
+// header so the output always parses.
+func (s *Store) ExportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"name", "seconds", "hours", "created_at", "active"}); err != nil {
+		return err
+	}
+	var total int64
+	for _, st := range s.Streams {
+		secs := int64(s.Elapsed(st.ID) / time.Second)
+		total += secs
+		row := []string{st.Name, strconv.FormatInt(secs, 10), csvHours(secs),
+			st.CreatedAt.Format(time.RFC3339), strconv.FormatBool(st.Active)}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write([]string{"Total", strconv.FormatInt(total, 10), csvHours(total), "", strconv.FormatBool(s.HasActive())}); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvHours renders secs as decimal hours to two places, e.g. "1.25".
+func csvHours(secs int64) string {
+	return strconv.FormatFloat(float64(secs)/3600, 'f', 2, 64)
+}
+
+// AddPastSession records a completed block of work on stream id: a closed
+// session from start to start+dur with a single span for the stream. Unlike
+// AddPastTime the block is attributed, so it adds the same amount to the
+// wall clock and to the stream's total. Blocks overlapping an existing
+// session are refused — the wall clock sums sessions, so the overlap would
+// be counted twice — as are blocks ending in the future.
+func (s *Store) AddPastSession(id string, start time.Time, dur time.Duration) error {
+	if s.StreamByID(id) == nil {
+		return fmt.Errorf("stream not found")
+	}
+	if dur <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	now := time.Now()
+	end := start.Add(dur)
+	if end.After(now) {
+		return fmt.Errorf("session can't end in the future")
+	}
+	for _, sess := range s.Sessions {
+		sessEnd := now
+		if sess.End != nil {
+			sessEnd = *sess.End
+		}
+		if start.Before(sessEnd) && end.After(sess.Start) {
+			return fmt.Errorf("overlaps the session %s", formatSpan(sess.Start, sess.End))
+		}
+	}
+	spanEnd := end
+	s.Sessions = append(s.Sessions, Session{
+		Start: start,
+		End:   &end,
+		Spans: []Span{{StreamID: id, Start: start, End: &spanEnd}},
+	})
+	s.LogEvent(EventEdit, id, now, "session added "+formatSpan(start, &end))
+	return nil
+}
+
+// DeleteSession removes the session at the given index by splice-removing it
+// from the Sessions slice. This is index-based (not ID-based like DeleteStream)
+// because sessions don't have unique identifiers — they're identified by
+// position in the sorted list shown to the user.
+func (s *Store) DeleteSession(index int) {
+	if index < 0 || index >= len(s.Sessions) {
+		return
+	}
+	sess := s.Sessions[index]
+	s.LogEvent(EventEdit, "", time.Now(), "session deleted "+formatSpan(sess.Start, sess.End))
+	s.Sessions = append(s.Sessions[:index], s.Sessions[index+1:]...)
+}
+
+// UpdateSession replaces the start and end times of the session at the given
+// index.
+func (s *Store) UpdateSession(index int, start time.Time, end *time.Time) error {
+	if index < 0 || index >= len(s.Sessions) {
+		return fmt.Errorf("session index out of range")
+	}
+	old := s.Sessions[index]
+	s.LogEvent(EventEdit, "", time.Now(), fmt.Sprintf("session changed from %s to %s",
+		formatSpan(old.Start, old.End), formatSpan(start, end)))
+	s.Sessions[index].Start = start
+	s.Sessions[index].End = end
+	return nil
+}
+
+// formatSpan renders a session's bounds for an event detail; an open end is
+// shown as "now".
+func formatSpan(start time.Time, end *time.Time) string {
+	const layout = "2006-01-02 15:04"
+	if end == nil {
+		return start.Format(layout) + "–now"
+	}
+	return start.Format(layout) + "–" + end.Format(layout)
+}
+
+// SortSessionsDesc sorts sessions by start time descending (newest first).
+// This is the display order for the session list view — the most recent
+// session is at the top, matching how users think about their recent work.
+func (s *Store) SortSessionsDesc() {
+	sort.SliceStable(s.Sessions, func(i, j int) bool {
+		return s.Sessions[i].Start.After(s.Sessions[j].Start)
+	})
+}
+
+// isActive reports whether the stream with the given ID exists and is
+// running.
+func (s *Store) isActive(id string) bool {
+	st := s.StreamByID(id)
+	return st != nil && st.Active
+}
+
+// StreamByID returns the stream with the given ID, or nil if there is none.
+// Trashed streams aren't included. The pointer is into s.Streams, so
+// changes through it are changes to the store, and it is only valid until
+// the slice next changes shape: adding, deleting or sorting streams.
+func (s *Store) StreamByID(id string) *Stream {
+	if i := s.indexOf(id); i >= 0 {
+		return &s.Streams[i]
+	}
+	return nil
+}
+
+// ActiveStreams returns the running streams in list order, as pointers
+// into s.Streams with the same lifetime as StreamByID's.
+func (s *Store) ActiveStreams() []*Stream {
+	var active []*Stream
+	for i := range s.Streams {
+		if s.Streams[i].Active {
+			active = append(active, &s.Streams[i])
+		}
+	}
+	return active
+}
+
+// indexOf returns the position of stream id in s.Streams, or -1. It's for
+// the index-based helpers like StopStream; everything else uses StreamByID.
+func (s *Store) indexOf(id string) int {
+	return slices.IndexFunc(s.Streams, func(st Stream) bool { return st.ID == id })
+}
+
+func (s *Store) HasActive() bool {
+	for _, st := range s.Streams {
+		if st.Active {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort modes for Store.SortMode. The zero value is the original order:
+// active streams first, then by creation time.
+const (
+	SortActive  = ""
+	SortAlpha   = "alpha"
+	SortCreated = "created"
+	SortElapsed = "elapsed"
+	SortRecent  = "recent"
+)
+
+// sortModes is the cycle order for NextSortMode, with a label for each.
+var sortModes = []struct{ mode, label string }{
+	{SortActive, "active first"},
+	{SortAlpha, "name"},
+	{SortCreated, "created"},
+	{SortElapsed, "most time"},
+	{SortRecent, "recently active"},
+}
+
+// ValidSortMode reports whether mode is one of sortModes.
+func ValidSortMode(mode string) bool {
+	for _, m := range sortModes {
+		if m.mode == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// NextSortMode switches SortMode to the next entry in sortModes and
+// re-sorts. An unknown mode from a hand-edited file cycles back to the
+// default.
+func (s *Store) NextSortMode() {
+	next := 0
+	for i, m := range sortModes {
+		if m.mode == s.SortMode {
+			next = (i + 1) % len(sortModes)
+		}
+	}
+	s.SortMode = sortModes[next].mode
+	s.SortStreams()
+}
+
+// SortLabel describes SortMode for display.
+func (s *Store) SortLabel() string {
+	for _, m := range sortModes {
+		if m.mode == s.SortMode {
+			return m.label
+		}
+	}
+	return sortModes[0].label
+}
+
+// SortStreams orders the list by SortMode, with archived streams always
+// last. Pinned streams sit above all of that in every mode and are never
+// compared with each other, so SliceStable leaves them in their manual
+// order — the slice position is the order, and it is persisted as-is.
+// SliceStable also means streams with equal keys preserve their relative
+// order, avoiding visual jitter in the TUI. Elapsed and last-activity times
+// are computed once up front, since each is a walk over every session.
+func (s *Store) SortStreams() {
+	var keys map[string]time.Duration
+	var recent map[string]time.Time
+	switch s.SortMode {
+	case SortElapsed:
+		keys = make(map[string]time.Duration, len(s.Streams))
+		for _, st := range s.Streams {
+			keys[st.ID] = s.Elapsed(st.ID)
+		}
+	case SortRecent:
+		now := time.Now()
+		recent = make(map[string]time.Time, len(s.Streams))
+		for _, st := range s.Streams {
+			recent[st.ID] = s.lastActive(st, now)
+		}
+	}
+	sort.SliceStable(s.Streams, func(i, j int) bool {
+		a, b := s.Streams[i], s.Streams[j]
+		if a.Archived != b.Archived {
+			return !a.Archived
+		}
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if a.Pinned {
+			return false
+		}
+		switch s.SortMode {
+		case SortAlpha:
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		case SortCreated:
+			return a.CreatedAt.Before(b.CreatedAt)
+		case SortElapsed:
+			return keys[a.ID] > keys[b.ID]
+		case SortRecent:
+			return recent[a.ID].After(recent[b.ID])
+		}
+		if a.Active != b.Active {
+			return a.Active
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
+
+// lastActive is when st was last running: now if it still is (one shared
+// now, so running streams tie and keep their order), otherwise the end of
+// its latest run, or zero if it has never run.
+func (s *Store) lastActive(st Stream, now time.Time) time.Time {
+	if st.Active {
+		return now
+	}
+	var last time.Time
+	for _, r := range s.StreamHistory(st.ID) {
+		if r.End != nil && r.End.After(last) {
+			last = *r.End
+		}
+	}
+	return last
+}
@@ -0,0 +1,2362 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "urd.json")
+	return &Store{FilePath: path}
+}
+
+func TestLoadStoreNonExistent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+	s, err := LoadStore(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(s.Streams) != 0 {
+		t.Fatalf("expected empty streams, got %d", len(s.Streams))
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Test", 0)
+	if err := s.Save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := LoadStore(s.FilePath)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(loaded.Streams) != 1 {
+		t.Fatalf("expected 1 stream, got %d", len(loaded.Streams))
+	}
+	if loaded.Streams[0].Name != "Test" {
+		t.Fatalf("expected name 'Test', got %q", loaded.Streams[0].Name)
+	}
+}
+
+func TestAddStreamPositions(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 0)
+	s.AddStream("C", 1)
+
+	if len(s.Streams) != 3 {
+		t.Fatalf("expected 3 streams, got %d", len(s.Streams))
+	}
+	if s.Streams[0].Name != "B" {
+		t.Fatalf("expected 'B' at 0, got %q", s.Streams[0].Name)
+	}
+	if s.Streams[1].Name != "C" {
+		t.Fatalf("expected 'C' at 1, got %q", s.Streams[1].Name)
+	}
+	if s.Streams[2].Name != "A" {
+		t.Fatalf("expected 'A' at 2, got %q", s.Streams[2].Name)
+	}
+}
+
+func TestAddStreamEmptyList(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("First", 0)
+	if len(s.Streams) != 1 {
+		t.Fatalf("expected 1 stream, got %d", len(s.Streams))
+	}
+	if s.Streams[0].Name != "First" {
+		t.Fatalf("expected 'First', got %q", s.Streams[0].Name)
+	}
+}
+
+func TestAddStreamOutOfBounds(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 100)
+	if len(s.Streams) != 1 {
+		t.Fatalf("expected 1 stream, got %d", len(s.Streams))
+	}
+	s.AddStream("B", -5)
+	if s.Streams[0].Name != "B" {
+		t.Fatalf("expected 'B' at 0, got %q", s.Streams[0].Name)
+	}
+}
+
+func TestDeleteStream(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	id := s.Streams[0].ID
+	s.DeleteStream(id)
+
+	if len(s.Streams) != 1 {
+		t.Fatalf("expected 1 stream, got %d", len(s.Streams))
+	}
+	if s.Streams[0].Name != "B" {
+		t.Fatalf("expected 'B', got %q", s.Streams[0].Name)
+	}
+}
+
+func TestDeleteNonExistent(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.DeleteStream("bogus")
+	if len(s.Streams) != 1 {
+		t.Fatalf("expected 1 stream, got %d", len(s.Streams))
+	}
+}
+
+func TestToggleStreamActivateDeactivate(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+
+	s.ToggleStream(id)
+	if !s.Streams[0].Active {
+		t.Fatal("expected stream to be active")
+	}
+	if s.Streams[0].StartedAt == nil {
+		t.Fatal("expected StartedAt to be set")
+	}
+	if len(s.Sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(s.Sessions))
+	}
+	if s.Sessions[0].End != nil {
+		t.Fatal("expected open session")
+	}
+
+	s.ToggleStream(id)
+	if s.Streams[0].Active {
+		t.Fatal("expected stream to be inactive")
+	}
+	if s.Sessions[0].End == nil {
+		t.Fatal("expected session to be closed")
+	}
+}
+
+func TestMultipleActiveStreams(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+
+	s.ToggleStream(s.Streams[0].ID)
+	s.ToggleStream(s.Streams[1].ID)
+
+	if !s.Streams[0].Active || !s.Streams[1].Active {
+		t.Fatal("expected both streams active")
+	}
+	// Only one session should be open (second activate doesn't create new session)
+	if len(s.Sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(s.Sessions))
+	}
+
+	// Deactivate one — session stays open
+	s.ToggleStream(s.Streams[0].ID)
+	if s.Sessions[0].End != nil {
+		t.Fatal("session should remain open with one active stream")
+	}
+
+	// Deactivate last — session closes
+	s.ToggleStream(s.Streams[1].ID)
+	if s.Sessions[0].End == nil {
+		t.Fatal("session should be closed")
+	}
+}
+
+func TestStopAll(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.ToggleStream(s.Streams[0].ID)
+	s.ToggleStream(s.Streams[1].ID)
+
+	s.StopAll()
+
+	if s.HasActive() {
+		t.Fatal("expected no active streams")
+	}
+	if s.Sessions[0].End == nil {
+		t.Fatal("expected session closed")
+	}
+}
+
+func TestStopAllRemembersLastActive(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	idA := s.Streams[0].ID
+	idB := s.Streams[1].ID
+
+	s.ToggleStream(idA)
+	s.ToggleStream(idB)
+	s.StopAll()
+
+	if len(s.LastActive) != 2 {
+		t.Fatalf("expected 2 last active, got %d", len(s.LastActive))
+	}
+}
+
+func TestContinueAll(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	idA := s.Streams[0].ID
+	idB := s.Streams[1].ID
+
+	s.ToggleStream(idA)
+	s.ToggleStream(idB)
+	s.StopAll()
+	s.ContinueAll()
+
+	if !s.Streams[0].Active || !s.Streams[1].Active {
+		t.Fatal("expected A and B to be active again")
+	}
+	if s.Streams[2].Active {
+		t.Fatal("expected C to remain inactive")
+	}
+	if s.LastActive != nil {
+		t.Fatal("expected LastActive to be cleared")
+	}
+	// Should have a new open session
+	last := s.Sessions[len(s.Sessions)-1]
+	if last.End != nil {
+		t.Fatal("expected new open session")
+	}
+}
+
+func TestContinueAllNoOp(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.ContinueAll() // no last active
+	if s.HasActive() {
+		t.Fatal("expected no active streams")
+	}
+}
+
+func TestHasActive(t *testing.T) {
+	s := newTestStore(t)
+	if s.HasActive() {
+		t.Fatal("expected false for empty store")
+	}
+	s.AddStream("A", 0)
+	if s.HasActive() {
+		t.Fatal("expected false with inactive stream")
+	}
+	s.ToggleStream(s.Streams[0].ID)
+	if !s.HasActive() {
+		t.Fatal("expected true with active stream")
+	}
+}
+
+func TestSortStreams(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("First", 0)
+	s.AddStream("Second", 1)
+	s.AddStream("Third", 2)
+
+	now := time.Now()
+	s.Streams[2].Active = true
+	s.Streams[2].StartedAt = &now
+
+	s.SortStreams()
+
+	// Active stream should be first.
+	if s.Streams[0].Name != "Third" {
+		t.Fatalf("expected active stream first, got %q", s.Streams[0].Name)
+	}
+	// Inactive streams preserve creation order.
+	if s.Streams[1].Name != "First" {
+		t.Fatalf("expected 'First' second, got %q", s.Streams[1].Name)
+	}
+	if s.Streams[2].Name != "Second" {
+		t.Fatalf("expected 'Second' third, got %q", s.Streams[2].Name)
+	}
+}
+
+func TestTotalWallClock(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	end := now.Add(time.Hour)
+	s.Sessions = []Session{
+		{Start: now, End: &end},
+	}
+	wc := s.TotalWallClock()
+	if wc != time.Hour {
+		t.Fatalf("expected 1h, got %s", wc)
+	}
+}
+
+func TestSaveAtomicWrite(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	// Tmp file should not exist after save
+	if _, err := os.Stat(s.FilePath + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("expected tmp file to be removed")
+	}
+	// Actual file should exist
+	if _, err := os.Stat(s.FilePath); err != nil {
+		t.Fatalf("expected file to exist: %v", err)
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	end1 := now.Add(time.Hour)
+	end2 := now.Add(2 * time.Hour)
+	s.Sessions = []Session{
+		{Start: now, End: &end1},
+		{Start: now.Add(time.Hour), End: &end2},
+	}
+	s.DeleteSession(0)
+	if len(s.Sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(s.Sessions))
+	}
+	if s.Sessions[0].Start != now.Add(time.Hour) {
+		t.Fatal("expected second session to remain")
+	}
+}
+
+func TestDeleteSessionOutOfBounds(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	end := now.Add(time.Hour)
+	s.Sessions = []Session{{Start: now, End: &end}}
+	s.DeleteSession(5)  // out of bounds
+	s.DeleteSession(-1) // negative
+	if len(s.Sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(s.Sessions))
+	}
+}
+
+func TestUpdateSession(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	end := now.Add(2 * time.Hour)
+	s.Sessions = []Session{{Start: now, End: &end}}
+
+	newEnd := now.Add(time.Hour)
+	err := s.UpdateSession(0, now, &newEnd)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if s.Sessions[0].End.Sub(s.Sessions[0].Start) != time.Hour {
+		t.Fatal("expected session to be 1 hour")
+	}
+}
+
+func TestSortSessionsDesc(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	e1 := now.Add(time.Hour)
+	e2 := now.Add(3 * time.Hour)
+	s.Sessions = []Session{
+		{Start: now, End: &e1},
+		{Start: now.Add(2 * time.Hour), End: &e2},
+	}
+	s.SortSessionsDesc()
+	if !s.Sessions[0].Start.After(s.Sessions[1].Start) {
+		t.Fatal("expected newest session first")
+	}
+}
+
+func TestAddPastTime(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Now().Add(-time.Hour)
+	end := time.Now()
+	s.AddPastTime(start, end)
+	if len(s.Sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(s.Sessions))
+	}
+	if s.Sessions[0].End == nil {
+		t.Fatal("expected closed session")
+	}
+}
+
+func TestEnsureActiveIdempotent(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+
+	s.EnsureActive(id)
+	started := s.Streams[0].StartedAt
+	s.EnsureActive(id)
+
+	if !s.Streams[0].Active {
+		t.Fatal("expected stream to stay active")
+	}
+	if s.Streams[0].StartedAt != started {
+		t.Fatal("expected StartedAt to be untouched by repeated ensure")
+	}
+	if len(s.Sessions) != 1 || s.Sessions[0].End != nil {
+		t.Fatal("expected a single open session")
+	}
+}
+
+func TestEnsureStoppedIdempotent(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+
+	s.EnsureStopped(id) // already stopped: no-op
+	if s.HasActive() || len(s.Sessions) != 0 {
+		t.Fatal("expected no activity from stopping an inactive stream")
+	}
+
+	s.EnsureActive(id)
+	s.EnsureStopped(id)
+	s.EnsureStopped(id)
+	if s.HasActive() {
+		t.Fatal("expected stream to be stopped")
+	}
+	if len(s.Sessions) != 1 || s.Sessions[0].End == nil {
+		t.Fatal("expected a single closed session")
+	}
+}
+
+func TestInterruptionCaptureCycle(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Work", 0)
+	s.AddStream("Interruptions", 1)
+	work := s.Streams[0].ID
+	umbrella := s.Streams[1].ID
+	s.SetInterruptionStream(umbrella)
+
+	s.ToggleStream(work)
+	// Pause the real stream: the umbrella takes over and the session stays open.
+	s.ToggleStream(work)
+	if !s.Streams[1].Active {
+		t.Fatal("expected interruption stream to capture idle time")
+	}
+	if len(s.Sessions) != 1 || s.Sessions[0].End != nil {
+		t.Fatal("expected the original session to remain open")
+	}
+
+	// Resume the real stream: the umbrella is released, still one session.
+	s.ToggleStream(work)
+	if s.Streams[1].Active {
+		t.Fatal("expected interruption stream to stop on resume")
+	}
+	if !s.Streams[0].Active {
+		t.Fatal("expected real stream to be active")
+	}
+	if len(s.Sessions) != 1 || s.Sessions[0].End != nil {
+		t.Fatal("expected wall clock to be preserved in a single open session")
+	}
+
+	// Stopping everything bypasses capture.
+	s.StopAll()
+	if s.HasActive() {
+		t.Fatal("expected StopAll to stop the interruption stream too")
+	}
+}
+
+func TestInterruptionCaptureStopUmbrellaDirectly(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Interruptions", 0)
+	umbrella := s.Streams[0].ID
+	s.SetInterruptionStream(umbrella)
+
+	s.ToggleStream(umbrella)
+	s.ToggleStream(umbrella)
+	if s.HasActive() {
+		t.Fatal("expected the interruption stream to stop when toggled directly")
+	}
+
+	s.SetInterruptionStream(umbrella)
+	if s.InterruptionID != "" {
+		t.Fatal("expected setting the same stream again to clear capture")
+	}
+}
+
+func TestFocusStream(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	s.ToggleStream(s.Streams[0].ID)
+	s.ToggleStream(s.Streams[1].ID)
+
+	s.FocusStream(s.Streams[2].ID)
+
+	active := 0
+	for _, st := range s.Streams {
+		if st.Active {
+			active++
+		}
+	}
+	if active != 1 || !s.Streams[2].Active {
+		t.Fatalf("expected only C active, got %d active", active)
+	}
+	if len(s.Sessions) != 1 || s.Sessions[0].End != nil {
+		t.Fatal("expected the original session to stay open and unfragmented")
+	}
+}
+
+func TestFocusStreamFromIdle(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.FocusStream(s.Streams[0].ID)
+	if !s.Streams[0].Active {
+		t.Fatal("expected stream to be active")
+	}
+	if len(s.Sessions) != 1 || s.Sessions[0].End != nil {
+		t.Fatal("expected a new open session")
+	}
+	// Focusing the already-focused stream is a no-op.
+	s.FocusStream(s.Streams[0].ID)
+	if len(s.Sessions) != 1 || !s.Streams[0].Active {
+		t.Fatal("expected repeated focus to change nothing")
+	}
+}
+
+func TestEnforceSessionCap(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	start := time.Now().Add(-150 * time.Minute)
+	s.ToggleStreamAt(s.Streams[0].ID, start)
+	s.SessionCapMinutes = 60
+
+	now := time.Now()
+	before := s.TotalWallClock()
+	if !s.EnforceSessionCap(now) {
+		t.Fatal("expected the session to be split")
+	}
+
+	// 150 minutes with a 60 minute cap: two closed sessions plus an open one.
+	if len(s.Sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(s.Sessions))
+	}
+	for i, sess := range s.Sessions[:2] {
+		if sess.End == nil || sess.End.Sub(sess.Start) != time.Hour {
+			t.Fatalf("session %d: expected a closed 1h session", i)
+		}
+	}
+	open := s.Sessions[2]
+	if open.End != nil || !open.Start.Equal(start.Add(2*time.Hour)) {
+		t.Fatal("expected the remainder to stay open from the last split point")
+	}
+	if !s.Streams[0].StartedAt.Equal(open.Start) {
+		t.Fatal("expected StartedAt to be re-anchored to the split point")
+	}
+	if diff := s.TotalWallClock() - before; diff < 0 || diff > time.Second {
+		t.Fatalf("expected wall clock to be preserved, drifted by %s", diff)
+	}
+	if s.EnforceSessionCap(now) {
+		t.Fatal("expected no further split below the cap")
+	}
+}
+
+func TestEnforceSessionCapDisabled(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.ToggleStreamAt(s.Streams[0].ID, time.Now().Add(-10*time.Hour))
+	if s.EnforceSessionCap(time.Now()) {
+		t.Fatal("expected no split without a cap")
+	}
+	if len(s.Sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(s.Sessions))
+	}
+}
+
+func TestSaveFileMode(t *testing.T) {
+	s := newTestStore(t)
+	s.FileMode = 0600
+	// A stale temp file with looser permissions must not leak its mode.
+	if err := os.WriteFile(s.FilePath+".tmp", nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(s.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0600 {
+		t.Fatalf("expected mode 0600, got %o", got)
+	}
+}
+
+func TestSavePreservesSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.json")
+	link := filepath.Join(dir, "urd.json")
+	if err := os.WriteFile(target, []byte(`{"streams":[],"sessions":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	s, err := LoadStore(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddStream("A", 0)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("expected data file to remain a symlink")
+	}
+	loaded, err := LoadStore(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Streams) != 1 {
+		t.Fatal("expected the save to be written through to the target")
+	}
+}
+
+func TestDeleteMovesToTrash(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+	s.ToggleStream(id)
+	s.Streams[0].Active = false // performDelete deactivates before deleting
+	s.DeleteStream(id)
+
+	if len(s.Streams) != 0 {
+		t.Fatalf("expected stream removed from list, got %d", len(s.Streams))
+	}
+	if len(s.Trash) != 1 || s.Trash[0].DeletedAt == nil {
+		t.Fatal("expected stream in trash with DeletedAt set")
+	}
+	if s.Trash[0].StartedAt != nil {
+		t.Fatal("expected trashed stream to be inactive")
+	}
+
+	if err := s.RestoreStream(id); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if len(s.Streams) != 1 || len(s.Trash) != 0 {
+		t.Fatal("expected stream back in the list and trash empty")
+	}
+	if s.Streams[0].DeletedAt != nil {
+		t.Fatal("expected DeletedAt cleared on restore")
+	}
+	if err := s.RestoreStream(id); err == nil {
+		t.Fatal("expected error restoring a stream not in trash")
+	}
+}
+
+func TestPurgeTrashByAge(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	old := now.AddDate(0, 0, -31)
+	recent := now.AddDate(0, 0, -2)
+	s.Trash = []Stream{
+		{ID: "old", Name: "Old", DeletedAt: &old},
+		{ID: "new", Name: "New", DeletedAt: &recent},
+	}
+	if n := s.PurgeTrash(now); n != 1 {
+		t.Fatalf("expected 1 purged, got %d", n)
+	}
+	if len(s.Trash) != 1 || s.Trash[0].ID != "new" {
+		t.Fatal("expected only the recent entry to remain")
+	}
+
+	s.TrashDays = 1
+	s.PurgeTrash(now)
+	if len(s.Trash) != 0 {
+		t.Fatal("expected a shorter window to purge the remaining entry")
+	}
+}
+
+func TestLoadStorePurgesTrash(t *testing.T) {
+	s := newTestStore(t)
+	old := time.Now().AddDate(0, 0, -60)
+	s.Trash = []Stream{{ID: "old", Name: "Old", DeletedAt: &old}}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadStore(s.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Trash) != 0 {
+		t.Fatal("expected expired trash to be purged on load")
+	}
+}
+
+func TestStopAllExcept(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	for _, st := range s.Streams {
+		s.ToggleStream(st.ID)
+	}
+
+	s.StopAllExcept(s.Streams[1].ID)
+
+	if s.Streams[0].Active || s.Streams[2].Active {
+		t.Fatal("expected other streams to be stopped")
+	}
+	if !s.Streams[1].Active || s.Streams[1].StartedAt == nil {
+		t.Fatal("expected selected stream to keep running")
+	}
+	if len(s.Sessions) != 1 || s.Sessions[0].End != nil {
+		t.Fatal("expected the session to stay open")
+	}
+	if s.LastActive != nil {
+		t.Fatal("expected LastActive to be untouched")
+	}
+}
+
+func TestStopAllExceptInactiveSelection(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.ToggleStream(s.Streams[0].ID)
+
+	s.StopAllExcept(s.Streams[1].ID)
+
+	if s.HasActive() {
+		t.Fatal("expected nothing active")
+	}
+	if s.Sessions[0].End == nil {
+		t.Fatal("expected the session to close when nothing is left running")
+	}
+}
+
+func TestWallClockBetweenClipsBothEnds(t *testing.T) {
+	s := newTestStore(t)
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	// 22:00 on the 9th to 02:00 on the 11th: straddles both ends of the 10th.
+	start := day.Add(-2 * time.Hour)
+	end := day.Add(26 * time.Hour)
+	s.Sessions = []Session{{Start: start, End: &end}}
+
+	got := s.WallClockBetween(day, day.AddDate(0, 0, 1))
+	if got != 24*time.Hour {
+		t.Fatalf("expected 24h inside the range, got %s", got)
+	}
+	if got := s.WallClockBetween(time.Time{}, time.Time{}); got != 28*time.Hour {
+		t.Fatalf("expected unbounded range to count everything, got %s", got)
+	}
+	if got := s.WallClockBetween(end, time.Time{}); got != 0 {
+		t.Fatalf("expected nothing after the session ends, got %s", got)
+	}
+}
+
+func TestRenameStream(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Emial", 0)
+	id := s.Streams[0].ID
+	s.ToggleStream(id)
+	before := s.Streams[0]
+
+	s.RenameStream(id, "Email")
+
+	after := s.Streams[0]
+	if after.Name != "Email" {
+		t.Fatalf("expected 'Email', got %q", after.Name)
+	}
+	if after.ID != before.ID || !after.CreatedAt.Equal(before.CreatedAt) ||
+		after.Active != before.Active || after.StartedAt != before.StartedAt {
+		t.Fatal("expected rename to leave everything but the name untouched")
+	}
+}
+
+func TestWallClockByDaySplitsAtMidnight(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Date(2024, 3, 4, 22, 30, 0, 0, time.Local)
+	end := time.Date(2024, 3, 5, 1, 0, 0, 0, time.Local)
+	sameDayEnd := time.Date(2024, 3, 5, 10, 0, 0, 0, time.Local)
+	sameDayStart := sameDayEnd.Add(-time.Hour)
+	s.Sessions = []Session{
+		{Start: start, End: &end},
+		{Start: sameDayStart, End: &sameDayEnd},
+	}
+
+	days := s.WallClockByDay()
+	if got := days["2024-03-04"]; got != 90*time.Minute {
+		t.Fatalf("expected 1h30m on the 4th, got %s", got)
+	}
+	if got := days["2024-03-05"]; got != 2*time.Hour {
+		t.Fatalf("expected 2h on the 5th, got %s", got)
+	}
+	if len(days) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(days))
+	}
+}
+
+func TestDayStartHourShiftsDayBoundary(t *testing.T) {
+	s := newTestStore(t)
+	s.DayStartHour = 4
+	if got := s.dayKey(time.Date(2024, 3, 5, 2, 0, 0, 0, time.Local)); got != "2024-03-04" {
+		t.Fatalf("expected 02:00 to belong to the previous day, got %s", got)
+	}
+	if got := s.dayKey(time.Date(2024, 3, 5, 4, 0, 0, 0, time.Local)); got != "2024-03-05" {
+		t.Fatalf("expected 04:00 to start a new day, got %s", got)
+	}
+
+	// 22:30 to 05:00 crosses the 4am boundary, not midnight.
+	start := time.Date(2024, 3, 4, 22, 30, 0, 0, time.Local)
+	end := time.Date(2024, 3, 5, 5, 0, 0, 0, time.Local)
+	s.Sessions = []Session{{Start: start, End: &end}}
+	days := s.WallClockByDay()
+	if got := days["2024-03-04"]; got != 5*time.Hour+30*time.Minute {
+		t.Fatalf("expected 5h30m on the 4th, got %s", got)
+	}
+	if got := days["2024-03-05"]; got != time.Hour {
+		t.Fatalf("expected 1h on the 5th, got %s", got)
+	}
+}
+
+func TestWallClockByDayOpenSession(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.ToggleStreamAt(s.Streams[0].ID, time.Now().Add(-time.Minute))
+	today := time.Now().Format("2006-01-02")
+	if got := s.WallClockByDay()[today]; got < time.Minute {
+		// The session may have started yesterday if this runs just after
+		// midnight; in that case the total across days must still add up.
+		var total time.Duration
+		for _, d := range s.WallClockByDay() {
+			total += d
+		}
+		if total < time.Minute {
+			t.Fatalf("expected open session to count up to now, got %s", total)
+		}
+	}
+}
+
+func TestSpansFollowStreamSwitches(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+
+	s.ToggleStream(a)
+	s.ToggleStream(b) // overlap
+	s.ToggleStream(a) // switch to B only
+	s.StopAll()
+
+	if len(s.Sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(s.Sessions))
+	}
+	spans := s.Sessions[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].StreamID != a || spans[1].StreamID != b {
+		t.Fatal("expected spans for A then B")
+	}
+	for _, sp := range spans {
+		if sp.End == nil {
+			t.Fatal("expected all spans closed after StopAll")
+		}
+	}
+}
+
+func TestStreamTimeByDay(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Date(2024, 3, 4, 23, 0, 0, 0, time.Local)
+	switchAt := start.Add(90 * time.Minute)
+	end := start.Add(3 * time.Hour)
+	s.Sessions = []Session{{
+		Start: start,
+		End:   &end,
+		Spans: []Span{
+			{StreamID: "a", Start: start, End: &switchAt},
+			{StreamID: "b", Start: switchAt, End: &end},
+		},
+	}}
+
+	a := s.StreamTimeByDay("a")
+	if a["2024-03-04"] != time.Hour || a["2024-03-05"] != 30*time.Minute {
+		t.Fatalf("unexpected split for a: %v", a)
+	}
+	b := s.StreamTimeByDay("b")
+	if b["2024-03-05"] != 90*time.Minute || len(b) != 1 {
+		t.Fatalf("unexpected split for b: %v", b)
+	}
+	if len(s.StreamTimeByDay("missing")) != 0 {
+		t.Fatal("expected no time for an unknown stream")
+	}
+}
+
+func TestStreamTimeClippedToSession(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+	end := start.Add(time.Hour)
+	backdated := start.Add(-time.Hour)
+	s.Sessions = []Session{{
+		Start: start,
+		End:   &end,
+		// Left open by a crash; ends with its session.
+		Spans: []Span{{StreamID: "a", Start: backdated}},
+	}}
+	if got := s.StreamTimeByDay("a")["2024-03-04"]; got != time.Hour {
+		t.Fatalf("expected span clipped to the 1h session, got %s", got)
+	}
+}
+
+func TestEditSecondsAddsInLatestGap(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+	s.ToggleStream(b)
+
+	if err := s.EditSeconds(a, 15*60); err != nil {
+		t.Fatal(err)
+	}
+	if s.Elapsed(a) != 15*time.Minute || len(s.Sessions) != 2 {
+		t.Fatalf("expected a 15m session for A, got %s in %d sessions", s.Elapsed(a), len(s.Sessions))
+	}
+	added, open := s.Sessions[1], s.Sessions[0]
+	if added.End.After(open.Start) {
+		t.Fatal("expected the added session to end before the open one starts")
+	}
+	if err := s.EditSeconds("nope", 60); err == nil {
+		t.Fatal("expected an unknown stream to be refused")
+	}
+}
+
+func TestEditSecondsTrimsRunsNewestFirst(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+	t0 := time.Now().Add(-24 * time.Hour)
+	t1, t2, t3 := t0.Add(time.Hour), t0.Add(2*time.Hour), t0.Add(3*time.Hour)
+	s.Sessions = []Session{
+		{Start: t0, End: &t1, Spans: []Span{{StreamID: a, Start: t0, End: &t1}}},
+		{Start: t2, End: &t3, Spans: []Span{
+			{StreamID: b, Start: t2, End: &t3},
+			{StreamID: a, Start: t2.Add(30 * time.Minute), End: &t3},
+		}},
+	}
+
+	if err := s.EditSeconds(a, -45*60); err != nil {
+		t.Fatal(err)
+	}
+	if s.Elapsed(a) != 45*time.Minute || s.Elapsed(b) != time.Hour {
+		t.Fatalf("expected the newest run used up and 15m off the one before, got %s", s.Elapsed(a))
+	}
+	if !s.Sessions[0].End.Equal(t0.Add(45*time.Minute)) || s.TotalWallClock() != 105*time.Minute {
+		t.Fatalf("expected A's own session to shrink with it, got wall clock %s", s.TotalWallClock())
+	}
+
+	if err := s.EditSeconds(a, -10*3600); err != nil {
+		t.Fatal(err)
+	}
+	if s.Elapsed(a) != 0 || len(s.Sessions) != 1 || s.Elapsed(b) != time.Hour {
+		t.Fatal("expected taking off more than A has to clamp at zero and drop its emptied session")
+	}
+}
+
+func TestEditSecondsStopsRunningStreamInThePast(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	a := s.Streams[0].ID
+	start := time.Now().Add(-10 * time.Hour)
+	s.ToggleStreamAt(a, start)
+
+	if err := s.EditSeconds(a, -8*3600); err != nil {
+		t.Fatal(err)
+	}
+	if s.HasActive() || s.Sessions[0].End == nil {
+		t.Fatal("expected the stream stopped and its session closed")
+	}
+	if got := s.Elapsed(a); got < 2*time.Hour-time.Second || got > 2*time.Hour {
+		t.Fatalf("expected about 2h left, got %s", got)
+	}
+	if wall := s.TotalWallClock(); wall < 2*time.Hour-time.Second || wall > 2*time.Hour {
+		t.Fatal("expected the session to end when the stream was stopped")
+	}
+}
+
+func TestEditSecondsClampsRunningStream(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+	past := time.Now().Add(-5 * time.Hour)
+	end := past.Add(time.Hour)
+	s.Sessions = []Session{{Start: past, End: &end, Spans: []Span{{StreamID: b, Start: past, End: &end}}}}
+	s.ToggleStreamAt(a, time.Now().Add(-time.Hour))
+
+	if err := s.EditSeconds(a, -3*3600); err != nil {
+		t.Fatal(err)
+	}
+	if s.HasActive() || s.Elapsed(a) != 0 || len(s.Sessions) != 1 || s.TotalWallClock() != time.Hour {
+		t.Fatal("expected the run removed along with its session, leaving B's time alone")
+	}
+	if last := s.Events[len(s.Events)-1]; last.Type != EventEdit || last.StreamID != a {
+		t.Fatalf("expected the edit logged, got %+v", last)
+	}
+
+	s.ToggleStreamAt(b, time.Now().Add(-time.Hour))
+	s.ResetStream(b)
+	if err := s.EditSeconds(b, -3*3600); err != nil {
+		t.Fatal(err)
+	}
+	if s.HasActive() || s.Elapsed(b) > time.Second || s.StreamTimeBetween(b, time.Time{}, time.Time{}) < 2*time.Hour-time.Second {
+		t.Fatal("expected the reset stream stopped at zero with its earlier time kept")
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	s := newTestStore(t)
+	var empty strings.Builder
+	if err := s.ExportCSV(&empty); err != nil {
+		t.Fatal(err)
+	}
+	if empty.String() != "name,seconds,hours,created_at,active\nTotal,0,0.00,,false\n" {
+		t.Fatalf("expected only the header and a zero total, got %q", empty.String())
+	}
+
+	s.AddStream("Client, Inc", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+	start := time.Now().Add(-2 * time.Hour)
+	mid, end := start.Add(75*time.Minute), start.Add(90*time.Minute)
+	s.Sessions = []Session{{Start: start, End: &end, Spans: []Span{
+		{StreamID: a, Start: start, End: &mid},
+		{StreamID: b, Start: start, End: &end},
+	}}}
+
+	var out strings.Builder
+	if err := s.ExportCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	created := s.Streams[0].CreatedAt.Format(time.RFC3339)
+	if len(lines) != 4 || lines[1] != `"Client, Inc",4500,1.25,`+created+`,false` {
+		t.Fatalf("expected a quoted row with exact seconds, got %q", out.String())
+	}
+	if lines[3] != "Total,9900,2.75,,false" {
+		t.Fatalf("expected the total to sum the rows, got %q", lines[3])
+	}
+}
+
+func TestLoadStoreWithoutSpans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urd.json")
+	started := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	data := `{"streams":[{"id":"a","name":"A","active":true,"started_at":"` + started +
+		`","created_at":"` + started + `"}],"sessions":[{"start":"` + started + `"}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadStore(path)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	spans := s.Sessions[0].Spans
+	if len(spans) != 1 || spans[0].StreamID != "a" || spans[0].End != nil {
+		t.Fatalf("expected a running span backfilled for the active stream, got %+v", spans)
+	}
+	today := time.Now().Format("2006-01-02")
+	if s.StreamTimeByDay("a")[today] == 0 && time.Now().Hour() > 0 {
+		t.Fatal("expected the backfilled span to count toward today")
+	}
+}
+
+func TestArchiveStream(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Old", 0)
+	s.AddStream("Current", 1)
+	old := s.Streams[0].ID
+	s.ToggleStream(old)
+	wallClock := s.TotalWallClock()
+
+	s.ArchiveStream(old)
+	s.SortStreams()
+
+	if s.Streams[1].ID != old || !s.Streams[1].Archived {
+		t.Fatal("expected archived stream sorted last")
+	}
+	if s.HasActive() {
+		t.Fatal("expected archiving to stop the stream")
+	}
+	if s.TotalWallClock() < wallClock {
+		t.Fatal("expected wall clock to be unaffected by archiving")
+	}
+
+	s.UnarchiveStream(old)
+	if s.Streams[1].Archived {
+		t.Fatal("expected stream to be unarchived")
+	}
+}
+
+func TestDataPathPrecedence(t *testing.T) {
+	t.Chdir(t.TempDir())
+	xdg := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", xdg)
+	t.Setenv("URD_FILE", "")
+
+	got, err := DataPath("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(xdg, "urd", "urd.json"); got != want {
+		t.Fatalf("expected XDG default %q, got %q", want, got)
+	}
+	if _, err := os.Stat(filepath.Dir(got)); err != nil {
+		t.Fatal("expected the data directory to be created")
+	}
+
+	// A legacy urd.json in the working directory still wins over XDG.
+	if err := os.WriteFile("urd.json", []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := DataPath("", ""); got != "urd.json" {
+		t.Fatalf("expected legacy file, got %q", got)
+	}
+	if got, _ := DataPath("", "/tmp/config.json"); got != "/tmp/config.json" {
+		t.Fatalf("expected the config file's path to beat the legacy file, got %q", got)
+	}
+
+	t.Setenv("URD_FILE", "/tmp/env.json")
+	if got, _ := DataPath("", "/tmp/config.json"); got != "/tmp/env.json" {
+		t.Fatalf("expected URD_FILE, got %q", got)
+	}
+	if got, _ := DataPath("flag.json", ""); got != "flag.json" {
+		t.Fatalf("expected flag to win, got %q", got)
+	}
+}
+
+func TestPauseResumeSurvivesStopAll(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	a, b, c := s.Streams[0].ID, s.Streams[1].ID, s.Streams[2].ID
+	s.ToggleStream(a)
+	s.ToggleStream(b)
+
+	s.Pause()
+	if s.HasActive() || s.Sessions[0].End == nil {
+		t.Fatal("expected pause to stop everything and close the session")
+	}
+
+	// A stop/continue cycle in between must not clobber the paused set.
+	s.ToggleStream(c)
+	s.StopAll()
+	if len(s.LastActive) != 1 || len(s.Paused) != 2 {
+		t.Fatal("expected stop and pause memories to stay separate")
+	}
+
+	s.Resume()
+	if !s.isActive(a) || !s.isActive(b) || s.isActive(c) {
+		t.Fatal("expected exactly the paused set to resume")
+	}
+	last := s.Sessions[len(s.Sessions)-1]
+	if len(s.Sessions) != 3 || last.End != nil {
+		t.Fatal("expected resume to open a fresh session")
+	}
+	if s.Paused != nil {
+		t.Fatal("expected paused set cleared after resume")
+	}
+}
+
+func TestPauseWithNothingActiveKeepsPausedSet(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.ToggleStream(s.Streams[0].ID)
+	s.Pause()
+	s.Pause()
+	if len(s.Paused) != 1 {
+		t.Fatal("expected a second pause with nothing running to keep the set")
+	}
+}
+
+func TestElapsedSumsSpans(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+	e1 := start.Add(30 * time.Minute)
+	s2 := start.Add(2 * time.Hour)
+	e2 := s2.Add(15 * time.Minute)
+	s.Sessions = []Session{
+		{Start: start, End: &e1, Spans: []Span{{StreamID: "a", Start: start, End: &e1}}},
+		{Start: s2, End: &e2, Spans: []Span{{StreamID: "a", Start: s2, End: &e2}}},
+	}
+	if got := s.Elapsed("a"); got != 45*time.Minute {
+		t.Fatalf("expected 45m, got %s", got)
+	}
+}
+
+func TestTargetProgress(t *testing.T) {
+	st := Stream{}
+	if _, ok := st.TargetProgress(time.Hour); ok {
+		t.Fatal("expected no target by default")
+	}
+	st.TargetSeconds = int64((10 * time.Hour).Seconds())
+	frac, ok := st.TargetProgress(3 * time.Hour)
+	if !ok || frac != 0.3 {
+		t.Fatalf("expected 0.3, got %v (%v)", frac, ok)
+	}
+	if frac, _ := st.TargetProgress(15 * time.Hour); frac != 1.5 {
+		t.Fatalf("expected overshoot to exceed 1, got %v", frac)
+	}
+}
+
+func TestSetTargetClears(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.SetTarget(s.Streams[0].ID, 10*time.Hour)
+	if s.Streams[0].TargetSeconds != 36000 {
+		t.Fatalf("expected 36000, got %d", s.Streams[0].TargetSeconds)
+	}
+	s.SetTarget(s.Streams[0].ID, 0)
+	if s.Streams[0].TargetSeconds != 0 {
+		t.Fatal("expected zero to clear the target")
+	}
+}
+
+func streamNames(s *Store) []string {
+	var names []string
+	for _, st := range s.Streams {
+		names = append(names, st.Name)
+	}
+	return names
+}
+
+func TestMoveStreamPinsAndReorders(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	a, b, c := s.Streams[0], s.Streams[1], s.Streams[2]
+
+	s.MoveStream(c.ID, -1) // pin C
+	s.MoveStream(b.ID, -1) // pin B below C
+	if got := strings.Join(streamNames(s), ","); got != "C,B,A" {
+		t.Fatalf("expected pinned streams first, got %s", got)
+	}
+	s.MoveStream(b.ID, -1)
+	if got := strings.Join(streamNames(s), ","); got != "B,C,A" {
+		t.Fatalf("expected B moved above C, got %s", got)
+	}
+
+	// Activating an unpinned stream must not lift it above pinned ones.
+	s.ToggleStream(a.ID)
+	s.SortStreams()
+	if got := strings.Join(streamNames(s), ","); got != "B,C,A" {
+		t.Fatalf("expected manual order kept after sort, got %s", got)
+	}
+
+	// Moving past the end of the pinned block unpins.
+	s.MoveStream(c.ID, 1)
+	if s.Streams[2].ID != c.ID || s.Streams[2].Pinned {
+		t.Fatalf("expected C unpinned and auto-sorted, got %v", streamNames(s))
+	}
+}
+
+func TestMoveStreamIgnoresArchived(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a := s.Streams[0]
+	s.ArchiveStream(a.ID)
+	s.SortStreams()
+	s.MoveStream(a.ID, -1)
+	if s.Streams[1].ID != a.ID || s.Streams[1].Pinned {
+		t.Fatal("expected archived stream left in place")
+	}
+}
+
+func TestMergeStreamsUnionsSpans(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("email (old)", 0)
+	s.AddStream("Email", 1)
+	src, dst := s.Streams[0].ID, s.Streams[1].ID
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+	t1, t2, t3 := t0.Add(time.Hour), t0.Add(2*time.Hour), t0.Add(3*time.Hour)
+	s.Sessions = []Session{{
+		Start: t0,
+		End:   &t3,
+		Spans: []Span{
+			{StreamID: dst, Start: t0, End: &t2},
+			{StreamID: src, Start: t1, End: &t3},
+		},
+	}}
+	s.LastActive = []string{src, dst}
+
+	if err := s.MergeStreams(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Streams) != 1 || s.Streams[0].ID != dst {
+		t.Fatal("expected the source removed")
+	}
+	if got := s.Elapsed(dst); got != 3*time.Hour {
+		t.Fatalf("expected overlap counted once (3h), got %s", got)
+	}
+	if len(s.LastActive) != 1 || s.LastActive[0] != dst {
+		t.Fatalf("expected LastActive deduplicated, got %v", s.LastActive)
+	}
+}
+
+func TestMergeStreamsCarriesActiveSource(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	src, dst := s.Streams[0].ID, s.Streams[1].ID
+	s.ToggleStream(src)
+
+	if err := s.MergeStreams(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Streams[0].Active || s.openSession() == nil {
+		t.Fatal("expected the destination to keep running in the open session")
+	}
+	open := 0
+	for _, sp := range s.openSession().Spans {
+		if sp.StreamID == src {
+			t.Fatal("expected no spans left for the source")
+		}
+		if sp.End == nil {
+			open++
+		}
+	}
+	if open != 1 {
+		t.Fatalf("expected one open span, got %d", open)
+	}
+}
+
+func TestMergeStreamsKeepsNotesAndTarget(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	a, b, c := s.Streams[0].ID, s.Streams[1].ID, s.Streams[2].ID
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+	s.Streams[0].Notes = []Note{{At: t0, Text: "first"}, {At: t0.Add(2 * time.Hour), Text: "third"}}
+	s.Streams[0].TargetSeconds = 3600
+	s.Streams[1].Notes = []Note{{At: t0.Add(time.Hour), Text: "second"}}
+	s.Streams[1].TargetSeconds = 7200
+
+	if err := s.MergeStreams(a, b); err != nil {
+		t.Fatal(err)
+	}
+	dst := s.StreamByID(b)
+	var texts []string
+	for _, n := range dst.Notes {
+		texts = append(texts, n.Text)
+	}
+	if strings.Join(texts, ",") != "first,second,third" {
+		t.Fatalf("expected both streams' notes oldest first, got %v", texts)
+	}
+	if dst.TargetSeconds != 7200 {
+		t.Fatalf("expected the destination's own target kept, got %d", dst.TargetSeconds)
+	}
+
+	if err := s.MergeStreams(b, c); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.StreamByID(c).TargetSeconds; got != 7200 {
+		t.Fatalf("expected a destination without a target to take the source's, got %d", got)
+	}
+}
+
+func TestMergeStreamsMissingID(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	a := s.Streams[0].ID
+	if err := s.MergeStreams(a, "nope"); err == nil {
+		t.Fatal("expected error for a missing destination")
+	}
+	if err := s.MergeStreams("nope", a); err == nil {
+		t.Fatal("expected error for a missing source")
+	}
+	if err := s.MergeStreams(a, a); err == nil {
+		t.Fatal("expected error merging a stream into itself")
+	}
+}
+
+func TestSplitStreamMovesLatestTime(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Work", 0)
+	s.AddStream("Other", 1)
+	src, other := s.Streams[0].ID, s.Streams[1].ID
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+	t1, t2, t3 := t0.Add(time.Hour), t0.Add(2*time.Hour), t0.Add(3*time.Hour)
+	s.Sessions = []Session{{
+		Start: t0,
+		End:   &t3,
+		Spans: []Span{
+			{StreamID: src, Start: t0, End: &t1},
+			{StreamID: src, Start: t2, End: &t3},
+			{StreamID: other, Start: t0, End: &t3},
+		},
+	}}
+	wall := s.TotalWallClock()
+
+	if err := s.SplitStream(src, "Review", 90*60); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Streams) != 3 || s.Streams[1].Name != "Review" {
+		t.Fatal("expected the new stream right after the source")
+	}
+	review := s.Streams[1].ID
+	if got := s.Elapsed(review); got != 90*time.Minute {
+		t.Fatalf("expected 1h30m moved, got %s", got)
+	}
+	if got := s.Elapsed(src); got != 30*time.Minute {
+		t.Fatalf("expected 30m left on the source, got %s", got)
+	}
+	runs := s.StreamHistory(src)
+	if len(runs) != 1 || !runs[0].Start.Equal(t0) || !runs[0].End.Equal(t0.Add(30*time.Minute)) {
+		t.Fatalf("expected the source to keep its earliest time, got %+v", runs)
+	}
+	if s.Elapsed(other) != 3*time.Hour || s.TotalWallClock() != wall {
+		t.Fatal("expected other streams and the wall clock untouched")
+	}
+
+	if err := s.SplitStream(src, "More", 31*60); err == nil {
+		t.Fatal("expected an error splitting off more than the source has")
+	}
+	if err := s.SplitStream(src, "review", 60); err == nil {
+		t.Fatal("expected an error for a taken name")
+	}
+	if len(s.Streams) != 3 {
+		t.Fatal("expected a refused split to add no stream")
+	}
+}
+
+func TestSplitStreamLeavesRunningSpan(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+	s.ToggleStreamAt(id, time.Now().Add(-time.Hour))
+	if err := s.SplitStream(id, "B", 60); err == nil {
+		t.Fatal("expected running time not to count as splittable")
+	}
+}
+
+func TestColorRoundTrip(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.SetColor(s.Streams[0].ID, "4")
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadStore(s.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Streams[0].Color != "4" || loaded.Streams[1].Color != "" {
+		t.Fatalf("unexpected colors after reload: %q %q", loaded.Streams[0].Color, loaded.Streams[1].Color)
+	}
+	data, _ := os.ReadFile(s.FilePath)
+	if strings.Count(string(data), `"color"`) != 1 {
+		t.Fatal("expected the default color omitted from JSON")
+	}
+}
+
+func TestRepairFixesInconsistentFixture(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "urd-inconsistent.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "urd.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadStore(path)
+	if err != nil {
+		t.Fatalf("expected an inconsistent file to load, got %v", err)
+	}
+	if len(s.Repairs) != 1 {
+		t.Fatalf("expected load to close the open session, got %v", s.Repairs)
+	}
+
+	fixed := s.Repair()
+	if len(fixed) != 4 {
+		t.Fatalf("expected two clamped spans, a clamped session and a dropped span, got %v", fixed)
+	}
+	for _, sess := range s.Sessions {
+		if sess.End == nil || sess.End.Before(sess.Start) {
+			t.Fatalf("expected every session closed and forward, got %+v", sess)
+		}
+		for _, sp := range sess.Spans {
+			if sp.StreamID == "0f0f0f" || (sp.End != nil && sp.End.Before(sp.Start)) {
+				t.Fatalf("expected no backwards or orphaned spans, got %+v", sp)
+			}
+		}
+	}
+	if got := s.TotalWallClock(); got != 3*time.Hour+30*time.Minute {
+		t.Fatalf("expected 3h30m of wall clock, got %s", got)
+	}
+	if s.Elapsed("a1b2c3") != 2*time.Hour || s.Elapsed("d4e5f6") != 30*time.Minute {
+		t.Fatalf("expected stream time from the forward spans only, got %s and %s", s.Elapsed("a1b2c3"), s.Elapsed("d4e5f6"))
+	}
+	if again := s.Repair(); len(again) != 0 {
+		t.Fatalf("expected a repaired store to need nothing more, got %v", again)
+	}
+}
+
+func TestLoadStoreMigratesV0(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "urd-v0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "urd.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadStore(path)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the rewrite to keep mode 0600, got %o", info.Mode().Perm())
+	}
+	if s.Version != currentVersion {
+		t.Fatalf("expected version %d, got %d", currentVersion, s.Version)
+	}
+	if len(s.Streams) != 2 || len(s.Sessions) != 2 || s.LastActive[0] != "d4e5f6" {
+		t.Fatal("expected v0 data preserved")
+	}
+	spans := s.Sessions[1].Spans
+	if len(spans) != 1 || spans[0].StreamID != "a1b2c3" || spans[0].End != nil {
+		t.Fatalf("expected a running span for the active stream, got %+v", spans)
+	}
+
+	// The upgrade is written back so it only happens once.
+	var onDisk struct {
+		Version  int       `json:"version"`
+		Sessions []Session `json:"sessions"`
+	}
+	raw, _ := os.ReadFile(path)
+	if err := json.Unmarshal(raw, &onDisk); err != nil {
+		t.Fatal(err)
+	}
+	if onDisk.Version != currentVersion || len(onDisk.Sessions[1].Spans) != 1 {
+		t.Fatalf("expected the migrated store re-saved, got version %d", onDisk.Version)
+	}
+}
+
+func TestLoadStoreRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urd.json")
+	os.WriteFile(path, []byte(`{"version": 99, "streams": [], "sessions": []}`), 0644)
+	if _, err := LoadStore(path); err == nil {
+		t.Fatal("expected an error for a file from a newer urd")
+	}
+}
+
+func TestLoadStoreCurrentVersionNotRewritten(t *testing.T) {
+	s := newTestStore(t)
+	s.Version = currentVersion
+	s.AddStream("A", 0)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.Stat(s.FilePath)
+	old := before.ModTime().Add(-time.Hour)
+	os.Chtimes(s.FilePath, old, old)
+	if _, err := LoadStore(s.FilePath); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(s.FilePath)
+	if !after.ModTime().Equal(old) {
+		t.Fatal("expected an up-to-date file to be left alone on load")
+	}
+}
+
+func eventTypes(events []Event) string {
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return strings.Join(types, ",")
+}
+
+func TestEventLog(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+
+	s.ToggleStream(a)
+	s.ToggleStream(b)
+	s.StopAll()
+	s.ContinueAll()
+	s.RenameStream(a, "Alpha")
+	s.DeleteStream(b)
+
+	if got := eventTypes(s.EventsForStream(a)); got != "add,start,stop,start,edit" {
+		t.Fatalf("unexpected events for A: %s", got)
+	}
+	if got := eventTypes(s.EventsForStream(b)); got != "add,start,stop,start,stop,delete" {
+		t.Fatalf("unexpected events for B: %s", got)
+	}
+	if d := s.EventsForStream(a)[4].Detail; d != `renamed "A" to "Alpha"` {
+		t.Fatalf("unexpected rename detail %q", d)
+	}
+}
+
+func TestEventLogBackdatedStart(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	at := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	s.ToggleStreamAt(s.Streams[0].ID, at)
+	events := s.EventsForStream(s.Streams[0].ID)
+	if last := events[len(events)-1]; last.Type != EventStart || !last.At.Equal(at) {
+		t.Fatalf("expected start logged at the backdated time, got %+v", last)
+	}
+}
+
+func TestEventLogSessionEdits(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+	s.AddPastTime(start, start.Add(time.Hour))
+	s.DeleteSession(0)
+	if got := eventTypes(s.Events); got != "edit,edit" {
+		t.Fatalf("expected session edits logged, got %s", got)
+	}
+	if s.Events[0].StreamID != "" || !strings.Contains(s.Events[0].Detail, "2024-03-04 09:00") {
+		t.Fatalf("unexpected session event %+v", s.Events[0])
+	}
+}
+
+func TestDuplicateNamesRejected(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.AddStream("Email", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddStream("email", 1); err == nil {
+		t.Fatal("expected a case-insensitive duplicate to be rejected")
+	}
+	if len(s.Streams) != 1 {
+		t.Fatal("expected no stream added")
+	}
+
+	s.AddStream("Client", 1)
+	client := s.Streams[1].ID
+	if err := s.RenameStream(client, "EMAIL"); err == nil {
+		t.Fatal("expected rename onto an existing name to be rejected")
+	}
+	if err := s.RenameStream(client, "CLIENT"); err != nil {
+		t.Fatalf("expected a case-only rename of the same stream to work, got %v", err)
+	}
+
+	email := s.Streams[0].ID
+	s.DeleteStream(email)
+	s.AddStream("Email", 1)
+	if err := s.RestoreStream(email); err == nil {
+		t.Fatal("expected restore to refuse a name now taken")
+	}
+}
+
+func TestRapidTogglingDoesNotDrift(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+	for range 1000 {
+		s.ToggleStream(id)
+		s.ToggleStream(id)
+	}
+	// Spans keep full-precision timestamps and only the final sum is
+	// truncated, so per-stream time matches the wall clock it ran in.
+	var spans time.Duration
+	for _, sess := range s.Sessions {
+		for _, sp := range sess.Spans {
+			spans += sp.End.Sub(sp.Start)
+		}
+	}
+	if drift := spans - s.Elapsed(id); drift < 0 || drift >= time.Second {
+		t.Fatalf("expected drift under a second, got %s", drift)
+	}
+	if s.Elapsed(id) != s.TotalWallClock() {
+		t.Fatalf("expected stream time %s to equal wall clock %s", s.Elapsed(id), s.TotalWallClock())
+	}
+}
+
+func TestAddPastSession(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)
+
+	if err := s.AddPastSession(id, start, 90*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if s.Elapsed(id) != 90*time.Minute || s.TotalWallClock() != 90*time.Minute {
+		t.Fatalf("expected stream and wall clock to grow together, got %s / %s", s.Elapsed(id), s.TotalWallClock())
+	}
+
+	if err := s.AddPastSession(id, start.Add(time.Hour), time.Hour); err == nil {
+		t.Fatal("expected an overlapping block to be refused")
+	}
+	if err := s.AddPastSession(id, time.Now().Add(-time.Minute), time.Hour); err == nil {
+		t.Fatal("expected a block ending in the future to be refused")
+	}
+	if err := s.AddPastSession("nope", start.AddDate(0, 0, 1), time.Hour); err == nil {
+		t.Fatal("expected an unknown stream to be refused")
+	}
+	if err := s.AddPastSession(id, start.AddDate(0, 0, 1), 0); err == nil {
+		t.Fatal("expected a zero duration to be refused")
+	}
+	if len(s.Sessions) != 1 {
+		t.Fatal("expected refused blocks to leave sessions untouched")
+	}
+}
+
+func TestLoadStoreRecoversFromCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urd.json")
+	garbage := []byte(`{"streams": [{"id": "a", "na`)
+	if err := os.WriteFile(path, garbage, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadStore(path)
+	if err != nil {
+		t.Fatalf("expected recovery instead of an error, got %v", err)
+	}
+	if s.Recovery == nil || s.Recovery.FromTemp || len(s.Streams) != 0 {
+		t.Fatalf("expected a fresh store with a recovery note, got %+v", s.Recovery)
+	}
+	kept, err := os.ReadFile(s.Recovery.CorruptPath)
+	if err != nil || string(kept) != string(garbage) {
+		t.Fatal("expected the corrupt file preserved byte for byte")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected the corrupt file moved out of the way")
+	}
+}
+
+func TestLoadStoreRecoversFromTemp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urd.json")
+	os.WriteFile(path, []byte("\x00\x00garbage"), 0644)
+	good := newTestStore(t)
+	good.AddStream("Email", 0)
+	data, _ := json.Marshal(good)
+	os.WriteFile(path+".tmp", data, 0644)
+
+	s, err := LoadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Recovery == nil || !s.Recovery.FromTemp {
+		t.Fatal("expected recovery from the leftover temp file")
+	}
+	if len(s.Streams) != 1 || s.Streams[0].Name != "Email" {
+		t.Fatal("expected the temp file's streams")
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("expected the temp file to become the data file")
+	}
+	if reloaded, err := LoadStore(path); err != nil || reloaded.Recovery != nil || len(reloaded.Streams) != 1 {
+		t.Fatal("expected the recovered file to load cleanly next time")
+	}
+}
+
+func TestLoadStoreIgnoresUnusableTemp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urd.json")
+	os.WriteFile(path, []byte("{"), 0644)
+	os.WriteFile(path+".tmp", []byte("[]"), 0644)
+	s, err := LoadStore(path)
+	if err != nil || s.Recovery == nil || s.Recovery.FromTemp {
+		t.Fatalf("expected a fresh start when the temp file isn't a store, got %v", err)
+	}
+}
+
+func TestNotesRoundTrip(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	id := s.Streams[0].ID
+	s.AddNote(id, "  drafted the proposal ")
+	s.AddNote(id, "   ")
+	s.AddNote(id, "sent for review")
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(s.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), `"notes"`) != 1 {
+		t.Fatalf("expected notes to be omitted for streams without any:\n%s", data)
+	}
+
+	loaded, err := LoadStore(s.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := loaded.Streams[0].Notes
+	if len(notes) != 2 || notes[0].Text != "drafted the proposal" || notes[1].Text != "sent for review" {
+		t.Fatalf("unexpected notes after reload: %+v", notes)
+	}
+	if notes[0].At.IsZero() {
+		t.Fatal("expected notes to be timestamped")
+	}
+}
+
+func TestImportNames(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	input := "# sprint 12\n  Design  \n\nemail\nReview\nDesign\n"
+	n, err := s.ImportNames(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 streams added, got %d", n)
+	}
+	if got := strings.Join(streamNames(s), ","); got != "Email,Design,Review" {
+		t.Fatalf("unexpected streams: %v", got)
+	}
+}
+
+func activeNames(s *Store) []string {
+	var names []string
+	for _, st := range s.Streams {
+		if st.Active {
+			names = append(names, st.Name)
+		}
+	}
+	return names
+}
+
+func TestFocusModeKeepsOneStreamActive(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	a, b, c := s.Streams[0].ID, s.Streams[1].ID, s.Streams[2].ID
+
+	s.ToggleStream(a)
+	s.ToggleStream(b)
+	s.SetMode(ModeFocus)
+	if got := strings.Join(activeNames(s), ","); got != "B" {
+		t.Fatalf("expected entering focus mode to keep the latest stream, got %s", got)
+	}
+
+	s.ToggleStream(c)
+	if got := strings.Join(activeNames(s), ","); got != "C" {
+		t.Fatalf("expected starting C to stop B, got %s", got)
+	}
+	if len(s.Sessions) != 1 {
+		t.Fatalf("expected switching to keep one session, got %d", len(s.Sessions))
+	}
+
+	s.SetMode("")
+	s.ToggleStream(a)
+	if got := strings.Join(activeNames(s), ","); got != "A,C" {
+		t.Fatalf("expected multi mode to allow overlap again, got %s", got)
+	}
+}
+
+func TestFocusModeContinuesOneStream(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.ToggleStream(s.Streams[0].ID)
+	s.ToggleStream(s.Streams[1].ID)
+	s.StopAll()
+
+	s.SetMode(ModeFocus)
+	s.ContinueAll()
+	if n := len(activeNames(s)); n != 1 {
+		t.Fatalf("expected continue to resume one stream in focus mode, got %d", n)
+	}
+}
+
+func TestStreamHistory(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+	t0 := time.Now().Add(-3 * time.Hour)
+	t1, t2, t3 := t0.Add(30*time.Minute), t0.Add(time.Hour), t0.Add(2*time.Hour)
+	s.Sessions = []Session{
+		// Stored newest first, as the session view leaves them.
+		{Start: t3, Spans: []Span{{StreamID: a, Start: t3}}},
+		{Start: t0, End: &t2, Spans: []Span{
+			{StreamID: a, Start: t0, End: &t1},
+			{StreamID: b, Start: t0, End: &t2},
+			{StreamID: a, Start: t1.Add(10 * time.Minute)}, // left open, ends with the session
+		}},
+	}
+	s.Streams[0].Active = true
+	s.Streams[0].StartedAt = &t3
+
+	runs := s.StreamHistory(a)
+	if len(runs) != 3 {
+		t.Fatalf("expected 3 runs, got %d", len(runs))
+	}
+	if !runs[0].Start.Equal(t0) || !runs[0].End.Equal(t1) {
+		t.Fatalf("unexpected first run %+v", runs[0])
+	}
+	if runs[1].End == nil || !runs[1].End.Equal(t2) {
+		t.Fatalf("expected an open span in a closed session to end with it, got %+v", runs[1])
+	}
+	if runs[2].End != nil || !runs[2].Start.Equal(t3) {
+		t.Fatalf("expected the current run last and still running, got %+v", runs[2])
+	}
+	if got := s.StreamHistory(b); len(got) != 1 {
+		t.Fatalf("expected B's history to be separate, got %d runs", len(got))
+	}
+}
+
+func TestHasRecordedTime(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	s.AddStream("C", 2)
+	a, b, c := s.Streams[0].ID, s.Streams[1].ID, s.Streams[2].ID
+	t0 := time.Now().Add(-time.Hour)
+	t1 := t0.Add(time.Minute)
+	s.Sessions = []Session{{Start: t0, End: &t1, Spans: []Span{
+		{StreamID: a, Start: t0, End: &t1},
+		{StreamID: b, Start: t1, End: &t1}, // toggled twice at once
+	}}}
+
+	for _, tt := range []struct {
+		id   string
+		want bool
+	}{{a, true}, {b, false}, {c, false}} {
+		if got := s.HasRecordedTime(tt.id); got != tt.want || got != (len(s.StreamHistory(tt.id)) > 0) {
+			t.Errorf("%s: expected %v to agree with StreamHistory, got %v", s.StreamByID(tt.id).Name, tt.want, got)
+		}
+	}
+}
+
+func TestLoadClosesOrphanedOpenSession(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	a := s.Streams[0].ID
+	start := time.Now().Add(-48 * time.Hour)
+	spanEnd := start.Add(time.Hour)
+	// Open session, but A was stopped and nothing else is running.
+	s.Sessions = []Session{{Start: start, Spans: []Span{{StreamID: a, Start: start, End: &spanEnd}}}}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadStore(s.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := loaded.Sessions[0]
+	if sess.End == nil || !sess.End.Equal(spanEnd) {
+		t.Fatalf("expected the session closed where its last span ended, got %v", sess.End)
+	}
+	if loaded.TotalWallClock() != time.Hour {
+		t.Fatalf("expected 1h of wall clock, got %s", loaded.TotalWallClock())
+	}
+	if len(loaded.Repairs) != 1 {
+		t.Fatalf("expected the repair to be reported, got %v", loaded.Repairs)
+	}
+}
+
+func TestLoadReopensSessionForRunningStreams(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	prevStart := time.Now().Add(-3 * time.Hour)
+	prevEnd := prevStart.Add(time.Hour)
+	s.Sessions = []Session{{Start: prevStart, End: &prevEnd}}
+	started := prevStart.Add(30 * time.Minute) // overlaps the closed session
+	s.Streams[0].Active = true
+	s.Streams[0].StartedAt = &started
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadStore(s.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Sessions) != 2 || loaded.Sessions[1].End != nil {
+		t.Fatalf("expected an open session to be added, got %+v", loaded.Sessions)
+	}
+	if !loaded.Sessions[1].Start.Equal(prevEnd) || !loaded.Streams[0].StartedAt.Equal(prevEnd) {
+		t.Fatal("expected the new session to start after the previous one")
+	}
+	if d := loaded.Elapsed(loaded.Streams[0].ID); d < 2*time.Hour-time.Minute {
+		t.Fatalf("expected A to be credited since the reopened start, got %s", d)
+	}
+}
+
+func TestStaleSince(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	now := time.Now()
+	s.ToggleStreamAt(s.Streams[0].ID, now.Add(-20*time.Hour))
+	if _, ok := s.StaleSince(now); ok {
+		t.Fatal("expected no stale warning without a save time")
+	}
+	saved := now.Add(-19 * time.Hour)
+	s.LastSavedAt = &saved
+	if got, ok := s.StaleSince(now); !ok || !got.Equal(saved) {
+		t.Fatalf("expected stale since the last save, got %v %v", got, ok)
+	}
+	recent := now.Add(-time.Hour)
+	s.LastSavedAt = &recent
+	if _, ok := s.StaleSince(now); ok {
+		t.Fatal("expected a recent save not to be stale")
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if s.LastSavedAt == nil || time.Since(*s.LastSavedAt) > time.Minute {
+		t.Fatal("expected Save to stamp LastSavedAt")
+	}
+}
+
+func TestClockSteppedBackwardNeverCountsNegativeTime(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a, b := s.Streams[0].ID, s.Streams[1].ID
+	if err := s.AddPastSession(b, time.Now().Add(-3*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// A started an hour "later" than now: what a running stream looks like
+	// after the clock is stepped back by an hour.
+	s.ToggleStreamAt(a, time.Now().Add(time.Hour))
+	if s.Elapsed(a) != 0 || s.CurrentSessionDuration() != 0 || s.TotalWallClock() != time.Hour {
+		t.Fatalf("expected nothing counted while the clock is behind, got %s, %s, %s",
+			s.Elapsed(a), s.CurrentSessionDuration(), s.TotalWallClock())
+	}
+
+	s.ToggleStream(a)
+	sess := s.Sessions[len(s.Sessions)-1]
+	if sess.End == nil || sess.End.Before(sess.Start) {
+		t.Fatalf("expected the session closed no earlier than it started, got %+v", sess)
+	}
+	for _, sp := range sess.Spans {
+		if sp.End == nil || sp.End.Before(sp.Start) {
+			t.Fatalf("expected the span closed no earlier than it started, got %+v", sp)
+		}
+	}
+	if s.Elapsed(a) != 0 || s.Elapsed(b) != time.Hour || s.TotalWallClock() != time.Hour {
+		t.Fatal("expected the other totals unchanged by the step")
+	}
+	if fixed := s.Repair(); len(fixed) != 0 {
+		t.Fatalf("expected nothing left for Repair, got %v", fixed)
+	}
+}
+
+func TestCurrentSessionDuration(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+	if err := s.AddPastSession(id, time.Now().Add(-5*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.CurrentSessionDuration(); got != 0 {
+		t.Fatalf("expected zero with no open session, got %s", got)
+	}
+	s.ToggleStreamAt(id, time.Now().Add(-45*time.Minute))
+	if got := s.CurrentSessionDuration(); got < 45*time.Minute || got > 46*time.Minute {
+		t.Fatalf("expected only the open session's 45m, got %s", got)
+	}
+}
+
+func TestLastActivity(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	if _, ok := s.LastActivity(); ok {
+		t.Fatal("expected no last activity without sessions")
+	}
+	id := s.Streams[0].ID
+	now := time.Now()
+	if err := s.AddPastSession(id, now.Add(-3*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddPastSession(id, now.Add(-10*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := s.LastActivity(); !ok || !got.Equal(now.Add(-2*time.Hour)) {
+		t.Fatalf("expected the latest session end whatever the order, got %v %v", got, ok)
+	}
+	s.ToggleStream(id)
+	if got, _ := s.LastActivity(); time.Since(got) > time.Second {
+		t.Fatalf("expected now while a stream runs, got %v", got)
+	}
+}
+
+func TestCloneIsDeep(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	a := s.Streams[0].ID
+	s.ToggleStream(a)
+	s.ToggleStream(s.Streams[1].ID)
+	s.StopAll()
+	s.ToggleStream(a)
+	s.AddNote(a, "first")
+	saved := time.Now()
+	s.LastSavedAt = &saved
+
+	c := s.Clone()
+	origStarted := *s.Streams[0].StartedAt
+	origEnd := *s.Sessions[0].End
+	origSpanEnd := *s.Sessions[0].Spans[0].End
+
+	*c.Streams[0].StartedAt = origStarted.Add(-time.Hour)
+	*c.Sessions[0].End = origEnd.Add(time.Hour)
+	*c.Sessions[0].Spans[0].End = origSpanEnd.Add(time.Hour)
+	*c.LastSavedAt = saved.Add(time.Hour)
+	c.Streams[0].Name = "changed"
+	c.Streams[0].Notes[0].Text = "changed"
+	c.Sessions[0].Spans[0].StreamID = "changed"
+	c.LastActive[0] = "changed"
+	c.Events[0].Type = "changed"
+	c.Sessions = append(c.Sessions[:1], Session{Start: origEnd})
+
+	if !s.Streams[0].StartedAt.Equal(origStarted) {
+		t.Fatal("StartedAt is shared with the clone")
+	}
+	if !s.Sessions[0].End.Equal(origEnd) || !s.Sessions[0].Spans[0].End.Equal(origSpanEnd) {
+		t.Fatal("session or span End is shared with the clone")
+	}
+	if !s.LastSavedAt.Equal(saved) {
+		t.Fatal("LastSavedAt is shared with the clone")
+	}
+	if s.Streams[0].Name != "A" || s.Streams[0].Notes[0].Text != "first" {
+		t.Fatal("stream data is shared with the clone")
+	}
+	if s.Sessions[0].Spans[0].StreamID != a || s.LastActive[0] == "changed" || s.Events[0].Type == "changed" {
+		t.Fatal("a backing array is shared with the clone")
+	}
+	if len(s.Sessions) != 2 || s.Sessions[1].End != nil {
+		t.Fatal("appending to the clone's sessions reached the original")
+	}
+}
+
+func TestSortModes(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("beta", 0)
+	s.AddStream("Alpha", 1)
+	s.AddStream("gamma", 2)
+	beta, alpha, gamma := s.Streams[0].ID, s.Streams[1].ID, s.Streams[2].ID
+	base := time.Now().Add(-10 * time.Hour)
+	for i := range s.Streams {
+		s.Streams[i].CreatedAt = base.Add(time.Duration(i) * time.Minute)
+	}
+	run := func(id string, start, end time.Time) {
+		s.Sessions = append(s.Sessions, Session{Start: start, End: &end, Spans: []Span{{StreamID: id, Start: start, End: &end}}})
+	}
+	run(alpha, base, base.Add(3*time.Hour))                  // most time, longest ago
+	run(gamma, base.Add(4*time.Hour), base.Add(5*time.Hour)) // most recent
+	s.ToggleStream(beta)                                     // running now, least time
+
+	tests := []struct {
+		mode, want string
+	}{
+		{SortActive, "beta,Alpha,gamma"},
+		{SortAlpha, "Alpha,beta,gamma"},
+		{SortCreated, "beta,Alpha,gamma"},
+		{SortElapsed, "Alpha,gamma,beta"},
+		{SortRecent, "beta,gamma,Alpha"},
+	}
+	for _, tt := range tests {
+		s.SortMode = tt.mode
+		s.SortStreams()
+		if got := strings.Join(streamNames(s), ","); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.mode, got, tt.want)
+		}
+	}
+
+	s.SortMode = SortRecent
+	s.NextSortMode()
+	if s.SortMode != SortActive {
+		t.Fatalf("expected the cycle to wrap to the default, got %q", s.SortMode)
+	}
+}
+
+func TestResetStreamZeroesCounterButKeepsHistory(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+	now := time.Now()
+	if err := s.AddPastSession(id, now.Add(-3*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	s.ToggleStreamAt(id, now.Add(-10*time.Minute))
+
+	s.ResetStream(id)
+	if got := s.Elapsed(id); got > time.Second {
+		t.Fatalf("expected the counter zeroed, got %v", got)
+	}
+	if !s.Streams[0].Active {
+		t.Fatal("expected a running stream to keep running")
+	}
+	if got := s.StreamTimeBetween(id, time.Time{}, time.Time{}); got < 70*time.Minute {
+		t.Fatalf("expected reports to keep the earlier time, got %v", got)
+	}
+	if got := s.ElapsedSince(id, now.Add(-4*time.Hour)); got > time.Second {
+		t.Fatalf("expected time before the reset excluded from ElapsedSince, got %v", got)
+	}
+
+	c := s.Clone()
+	*c.Streams[0].ResetAt = now.Add(-time.Hour)
+	if s.Elapsed(id) > time.Second {
+		t.Fatal("ResetAt is shared with the clone")
+	}
+}
+
+func TestAnchorCountsTotalsFromThere(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("A", 0)
+	id := s.Streams[0].ID
+	now := time.Now()
+	if err := s.AddPastSession(id, now.Add(-5*time.Hour), 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddPastSession(id, now.Add(-2*time.Hour), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// The anchor falls halfway through the second session.
+	anchor := now.Add(-90 * time.Minute)
+	s.SetAnchor(&anchor)
+	if got := s.TotalWallClock(); got != 30*time.Minute {
+		t.Fatalf("expected wall clock from the anchor, got %v", got)
+	}
+	if got := s.Elapsed(id); got != 30*time.Minute {
+		t.Fatalf("expected stream time from the anchor, got %v", got)
+	}
+	if got := s.WallClockBetween(time.Time{}, time.Time{}); got != 3*time.Hour {
+		t.Fatalf("expected the history kept for explicit ranges, got %v", got)
+	}
+	if len(s.Sessions) != 2 {
+		t.Fatal("expected no sessions removed")
+	}
+
+	s.SetAnchor(nil)
+	if got := s.TotalWallClock(); got != 3*time.Hour {
+		t.Fatalf("expected clearing the anchor to count everything, got %v", got)
+	}
+}
+
+func TestStreamByID(t *testing.T) {
+	s := newTestStore(t)
+	if s.StreamByID("missing") != nil {
+		t.Fatal("expected nil on an empty store")
+	}
+	s.AddStream("A", 0)
+	s.AddStream("B", 1)
+	id := s.Streams[1].ID
+
+	st := s.StreamByID(id)
+	if st == nil || st.Name != "B" {
+		t.Fatalf("expected B, got %+v", st)
+	}
+	st.Color = "4"
+	if s.Streams[1].Color != "4" {
+		t.Fatal("expected the pointer to write through to the store")
+	}
+	if s.StreamByID("") != nil || s.StreamByID("missing") != nil {
+		t.Fatal("expected nil for unknown IDs")
+	}
+	s.DeleteStream(id)
+	if s.StreamByID(id) != nil {
+		t.Fatal("expected trashed streams not to be found")
+	}
+}
+
+func TestActiveStreams(t *testing.T) {
+	s := newTestStore(t)
+	if got := s.ActiveStreams(); len(got) != 0 {
+		t.Fatalf("expected none on an empty store, got %d", len(got))
+	}
+	for i, name := range []string{"A", "B", "C"} {
+		s.AddStream(name, i)
+	}
+	if got := s.ActiveStreams(); len(got) != 0 {
+		t.Fatal("expected none while nothing runs")
+	}
+	s.ToggleStream(s.Streams[2].ID)
+	s.ToggleStream(s.Streams[0].ID)
+	got := s.ActiveStreams()
+	if len(got) != 2 || got[0].Name != "A" || got[1].Name != "C" {
+		t.Fatalf("expected A and C in list order, got %d", len(got))
+	}
+}
+
+func TestSaveRotatesBackups(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("first", 0)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	backup := func(n int) string { return fmt.Sprintf("%s.bak.%d", s.FilePath, n) }
+	if _, err := os.Stat(backup(1)); !os.IsNotExist(err) {
+		t.Fatal("expected no backups while BackupCount is zero")
+	}
+
+	s.BackupCount = 2
+	// ageBackups makes the newest backup look old enough to rotate again.
+	ageBackups := func() {
+		old := time.Now().Add(-2 * backupInterval)
+		if err := os.Chtimes(backup(1), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := []string{"first", "second", "third", "fourth"}
+	for i, name := range names[1:] {
+		s.AddStream(name, i+1)
+		if err := s.Save(); err != nil {
+			t.Fatal(err)
+		}
+		ageBackups()
+	}
+	// Read directly: LoadStore could migrate the backup and save it back.
+	backedUp := func(n int) int {
+		data, err := os.ReadFile(backup(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b Store
+		if err := json.Unmarshal(data, &b); err != nil {
+			t.Fatal(err)
+		}
+		return len(b.Streams)
+	}
+	if got := backedUp(1); got != 3 {
+		t.Fatalf("expected bak.1 to hold the file before the last save, got %d streams", got)
+	}
+	if got := backedUp(2); got != 2 {
+		t.Fatalf("expected bak.2 one save older, got %d streams", got)
+	}
+	if _, err := os.Stat(backup(3)); !os.IsNotExist(err) {
+		t.Fatal("expected only BackupCount backups kept")
+	}
+
+	// Within the interval, saves leave the backups alone.
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s.AddStream("fifth", 4)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := backedUp(1); got != 4 {
+		t.Fatalf("expected one rotation, then none within the interval, got %d streams", got)
+	}
+}
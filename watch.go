@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/fjordengineering/urd/store"
+)
+
+// clearScreen moves the cursor home and clears the terminal. Plain ANSI is
+// enough for --watch, which exists for terminals where the full TUI isn't
+// wanted.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// runWatch implements --watch: a live, non-interactive view of the store
+// for headless boxes and SSH sessions. It re-reads the data file every
+// interval rather than holding it in memory, so streams started elsewhere
+// (urd ensure from cron, or a TUI in another terminal) show up on the next
+// frame. Nothing is written, not even the migration or corrupt-file rename
+// a full load can do, so it neither takes nor needs the lock, and stopping
+// it (SIGINT) leaves tracking exactly as it was — just as quitting the TUI
+// does. cfg's preferences (sort order) are applied to every frame.
+func runWatch(path string, cfg *Config, out io.Writer, interval time.Duration, stop <-chan os.Signal) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s, err := store.LoadStoreReadOnly(path)
+		if err != nil {
+			return err
+		}
+		cfg.apply(s)
+		fmt.Fprint(out, clearScreen)
+		writeWatchFrame(out, s)
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeWatchFrame prints one frame of --watch: the non-archived streams in
+// list order with the TUI's columns, then the wall clock.
+func writeWatchFrame(w io.Writer, s *store.Store) {
+	s.SortStreams()
+	wallClock := s.TotalWallClock()
+	fmt.Fprintf(w, "urd - %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
+	for _, st := range s.Streams {
+		if st.Archived {
+			continue
+		}
+		marker := " "
+		if st.Active {
+			marker = "●"
+		}
+		elapsed := s.Elapsed(st.ID)
+		fmt.Fprintf(w, "%s %-20s  %11s  %3.0f%%\n", marker, st.Name, formatDuration(elapsed), percentOf(elapsed, wallClock))
+	}
+	fmt.Fprintf(w, "\nWall clock: %s\n", formatDuration(wallClock))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteWatchFrame(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	s.AddStream("Old", 1)
+	s.ArchiveStream(s.Streams[1].ID)
+	s.ToggleStream(s.Streams[0].ID)
+
+	var out strings.Builder
+	writeWatchFrame(&out, s)
+	frame := out.String()
+	if !strings.Contains(frame, "● Email") {
+		t.Fatalf("expected the active stream marked, got:\n%s", frame)
+	}
+	if strings.Contains(frame, "Old") {
+		t.Fatal("expected archived streams left out")
+	}
+	if !strings.Contains(frame, "Wall clock:") {
+		t.Fatal("expected the wall clock total")
+	}
+}
+
+func TestRunWatchRereadsAndStops(t *testing.T) {
+	s := newTestStore(t)
+	s.AddStream("Email", 0)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+	var out strings.Builder
+	if err := runWatch(s.FilePath, &Config{}, &out, time.Hour, stop); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), clearScreen) || !strings.Contains(out.String(), "Email") {
+		t.Fatalf("expected one frame read from the file, got %q", out.String())
+	}
+	if _, err := os.Stat(s.FilePath + ".lock"); !os.IsNotExist(err) {
+		t.Fatal("expected watch not to take the lock")
+	}
+}
+
+func TestRunWatchLeavesCorruptFileInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urd.json")
+	if err := os.WriteFile(path, []byte(`{"streams": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+	var out strings.Builder
+	if err := runWatch(path, &Config{}, &out, time.Hour, stop); err == nil {
+		t.Fatal("expected an error for an unreadable file")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != `{"streams": [` {
+		t.Fatalf("expected the corrupt file left untouched, got %q, %v", data, err)
+	}
+	if matches, _ := filepath.Glob(path + ".corrupt.*"); len(matches) != 0 {
+		t.Fatalf("expected nothing moved aside, got %v", matches)
+	}
+}